@@ -16,6 +16,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -30,7 +31,6 @@ import (
 	"github.com/leitstand/leitstand-template-engine/pkg/util"
 
 	"github.com/google/go-cmp/cmp"
-	"github.com/yosuke-furukawa/json5/encoding/json5"
 )
 
 func main() {
@@ -38,17 +38,31 @@ func main() {
 	template := flag.String("template", "", "Template name")
 	test := flag.String("test", "", "Name of the test")
 	skipValidation := flag.Bool("n", false, "skips the validation")
+	lint := flag.Bool("lint", false, "lints the template, with a test its variables are rendered as dry run")
+	skeleton := flag.Bool("skeleton", false, "writes the variables read by the template as variables file of the test")
 	//logging
 	debug := flag.Bool("debug", false, "turn on debug logging")
 	console := flag.Bool("console", true, "turn on pretty console logging")
 	flag.Parse()
 
-	if *templatePath == "" || *template == "" || *test == "" {
+	if *templatePath == "" || *template == "" || (*test == "" && !*lint) {
 		flag.Usage()
 		return
 	}
 	initializeLogger(debug, console)
 	r := configen.NewRepository(*templatePath)
+	defer r.Close()
+
+	if *lint {
+		if !lintTemplate(r, *templatePath, *template, *test) {
+			os.Exit(1)
+		}
+		return
+	}
+	if *skeleton {
+		writeSkeleton(r, *templatePath, *template, *test)
+		return
+	}
 
 	var variables map[string]interface{}
 	variablesFile := fmt.Sprintf("%s/%s/%s_variables.json", *templatePath, *template, *test)
@@ -58,7 +72,7 @@ func main() {
 		return
 	}
 
-	got, format, err := r.GenerateFile(*template, variables)
+	got, format, err := r.GenerateFile(context.Background(), *template, variables)
 	if err != nil {
 		log.Error().Err(err).Msg("error in generation")
 		return
@@ -77,28 +91,66 @@ func main() {
 			return
 		}
 
-		switch format {
-		case "json":
-			if diff := cmp.Diff(transformToJSONObject(want), transformToJSONObject(got)); diff != "" {
-				log.Warn().Msgf("mismatch (-want +got):\n%s", diff)
-				return
-			}
-		case "json5":
-			if diff := cmp.Diff(transformToJSON5Object(want), transformToJSON5Object(got)); diff != "" {
-				log.Warn().Msgf("mismatch (-want +got):\n%s", diff)
-				return
-			}
-		case "txt":
-			fallthrough
-		default:
-			if diff := cmp.Diff(string(want), string(got)); diff != "" {
-				log.Warn().Msgf("mismatch (-want +got):\n%s", diff)
-				return
-			}
+		if diff := cmp.Diff(configen.NormalizeOutput(format, want), configen.NormalizeOutput(format, got)); diff != "" {
+			log.Warn().Msgf("mismatch (-want +got):\n%s", diff)
+			return
 		}
 		log.Info().Msg("Success!")
 	}
 }
+
+// lintTemplate logs the issues of the template and returns whether the template is valid.
+func lintTemplate(r *configen.Repository, templatePath string, template string, test string) bool {
+	var variables map[string]interface{}
+	if test != "" {
+		variablesFile := fmt.Sprintf("%s/%s/%s_variables.json", templatePath, template, test)
+		if err := util.ReadJSONObject(variablesFile, &variables); err != nil {
+			log.Error().Err(err).Msg("can't find variables file")
+			return false
+		}
+	}
+	result, err := r.LintTemplate(context.Background(), template, variables)
+	if err != nil {
+		log.Error().Err(err).Msg("error in lint")
+		return false
+	}
+	for _, issue := range result.Issues {
+		event := log.Warn()
+		if issue.Severity == configen.LintError {
+			event = log.Error()
+		}
+		event.Str("rule", issue.Rule).Str("file", issue.File).Int("line", issue.Line).Int("column", issue.Column).Msg(issue.Message)
+	}
+	if result.Valid {
+		log.Info().Msgf("Lint passed with %d issues!", len(result.Issues))
+	}
+	return result.Valid
+}
+
+// writeSkeleton writes the skeleton variables of the template as variables file of the test, an existing file is kept.
+func writeSkeleton(r *configen.Repository, templatePath string, template string, test string) {
+	variablesFile := fmt.Sprintf("%s/%s/%s_variables.json", templatePath, template, test)
+	if _, err := os.Stat(variablesFile); err == nil {
+		log.Error().Msgf("variables file %s already exists", variablesFile)
+		return
+	}
+	variables, err := r.TemplateVariables(template)
+	if err != nil {
+		log.Error().Err(err).Msg("error in variable discovery")
+		return
+	}
+	content, err := json.MarshalIndent(variables.Skeleton, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("error in variable discovery")
+		return
+	}
+	if err := ioutil.WriteFile(variablesFile, append(content, '\n'), 0644); err != nil {
+		log.Error().Err(err).Msg("can't write variables file")
+		return
+	}
+	log.Info().Msgf("Wrote file %s with %d variables!", variablesFile, len(variables.Paths))
+}
+
 func initializeLogger(debug, console *bool) {
 	var w io.Writer
 	w = os.Stderr
@@ -111,17 +163,3 @@ func initializeLogger(debug, console *bool) {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
 }
-func transformToJSONObject(value []byte) interface{} {
-	var v interface{}
-	if err := json.Unmarshal(value, &v); err != nil {
-		return fmt.Sprintf("not parseable (%s)", value) // use unparseable input as the output
-	}
-	return v
-}
-func transformToJSON5Object(value []byte) interface{} {
-	var v interface{}
-	if err := json5.Unmarshal(value, &v); err != nil {
-		return value // use unparseable input as the output
-	}
-	return v
-}
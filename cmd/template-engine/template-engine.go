@@ -95,16 +95,23 @@ func main() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("startup error occurred")
 	}
+	if err := opts.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("startup error occurred")
+	}
 
-	if _, err := os.Stat(opts.TemplatePath); os.IsNotExist(err) {
-		log.Fatal().Err(err).Str("folder", opts.TemplatePath).Msg("Folder does not exist")
+	templateStore, err := configen.OpenStore(opts.TemplatePath)
+	if err != nil {
+		log.Fatal().Err(err).Str("template_path", opts.TemplatePath).Msg("Template path can't be opened")
 	}
 
 	// Initialize a new instance of application containing the dependencies.
 	jobRepository := job.NewDefaultRepository("/template-engine/api/v1/jobs")
 	jobApplication := jobRest.NewApplication(jobRepository)
 
-	configenRepository := configen.NewRepository(opts.TemplatePath)
+	configenRepository := configen.NewStoreRepository(templateStore,
+		configen.WithSandbox(opts.Sandbox),
+		configen.WithRenderTimeout(opts.RenderTimeoutDuration()),
+		configen.WithMaxOutputSize(opts.MaxOutputSize))
 	restApplication := rest.NewApplication(configenRepository, jobRepository)
 
 	staticFS, err := fs.New()
@@ -1,6 +1,6 @@
 module github.com/leitstand/leitstand-template-engine
 
-go 1.14
+go 1.16
 
 require (
 	github.com/Masterminds/goutils v1.1.0 // indirect
@@ -16,6 +16,7 @@ require (
 	github.com/matryer/is v1.4.0
 	github.com/mitchellh/copystructure v1.0.0 // indirect
 	github.com/pkg/errors v0.9.1
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/rakyll/statik v0.1.7
 	github.com/rs/zerolog v1.19.0
 	github.com/stretchr/testify v1.4.0
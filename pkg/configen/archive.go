@@ -0,0 +1,117 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxArchiveSize limits the total size of the files read from an archive.
+// Compressed archives can expand to any size, so the limit of the archive itself is not sufficient.
+const maxArchiveSize = 256 << 20
+
+// ReadTarGz reads the regular files of a tar.gz archive.
+// The result maps the file path inside the archive to the file content.
+func ReadTarGz(reader io.Reader) (map[string][]byte, error) {
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, errors.WithMessage(ErrInvalidTemplate, err.Error())
+	}
+	defer func() { _ = gzipReader.Close() }()
+	return readTar(gzipReader, maxArchiveSize)
+}
+
+// readTar reads the regular files of a tar archive up to the total size.
+func readTar(reader io.Reader, maxSize int64) (map[string][]byte, error) {
+	tarReader := tar.NewReader(reader)
+	files := make(map[string][]byte)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.WithMessage(ErrInvalidTemplate, err.Error())
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := readArchiveFile(tarReader, &maxSize)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = content
+	}
+	return files, nil
+}
+
+// readZip reads the regular files of a zip archive up to the total size.
+func readZip(content []byte, maxSize int64) (map[string][]byte, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, errors.WithMessage(ErrInvalidTemplate, err.Error())
+	}
+	files := make(map[string][]byte)
+	for _, file := range zipReader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, errors.WithMessage(ErrInvalidTemplate, err.Error())
+		}
+		fileContent, err := readArchiveFile(reader, &maxSize)
+		_ = reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[file.Name] = fileContent
+	}
+	return files, nil
+}
+
+// readArchiveFile reads a file of an archive and reduces the remaining size of the archive by the file size.
+func readArchiveFile(reader io.Reader, remaining *int64) ([]byte, error) {
+	content, err := ioutil.ReadAll(io.LimitReader(reader, *remaining+1))
+	if err != nil {
+		return nil, errors.WithMessage(ErrInvalidTemplate, err.Error())
+	}
+	if int64(len(content)) > *remaining {
+		return nil, errors.WithMessage(ErrInvalidTemplate, "archive content exceeds the size limit")
+	}
+	*remaining -= int64(len(content))
+	return content, nil
+}
+
+// cleanFilePath normalizes a file path of an uploaded template.
+// Paths leaving the template folder are rejected.
+func cleanFilePath(filePath string) (string, error) {
+	cleaned := path.Clean(strings.TrimPrefix(strings.ReplaceAll(filePath, "\\", "/"), "./"))
+	if cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.WithMessagef(ErrInvalidTemplate, "invalid file path %q", filePath)
+	}
+	return cleaned, nil
+}
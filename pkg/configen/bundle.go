@@ -0,0 +1,113 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Bundle is the result of a template generation.
+type Bundle struct {
+	// Files are the generated files, one for each output of the template.
+	Files []*BundleFile
+	// MultiFile is set if the template declares outputs.
+	// Templates without declared outputs generate exactly one file.
+	MultiFile bool
+}
+
+// BundleFile is a generated file of a bundle.
+type BundleFile struct {
+	// Name of the output
+	Name string
+	// Format of the output (e.g. json)
+	Format string
+	// Content is the generated content
+	Content []byte
+}
+
+// templateOutput is a prepared output of a parsed template.
+type templateOutput struct {
+	name           string
+	mainTemplate   string
+	format         string
+	postProcessors []PostProcessor
+}
+
+// validateVariables validates the variables against the variables schema of the template if there is one.
+func (t *cachedTemplate) validateVariables(variables map[string]interface{}) error {
+	if t.schema == nil {
+		return nil
+	}
+	return t.schema.validate(variables)
+}
+
+// render executes the main template of the output and applies the post processors of the output.
+// The execution is stopped if it exceeds the render timeout or the max output size.
+func (t *cachedTemplate) render(ctx context.Context, output *templateOutput, variables map[string]interface{}) ([]byte, error) {
+	if t.renderTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.renderTimeout)
+		defer cancel()
+	}
+	w := &renderWriter{ctx: ctx, maxSize: t.maxOutputSize}
+	err := t.templates.Execute(ctx, w, output.mainTemplate, variables)
+	result := w.close()
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	for _, processor := range output.postProcessors {
+		result, err = processor(result)
+		if err != nil {
+			return result, err
+		}
+	}
+	if t.config.ValidateOutput {
+		if err := validateOutput(output.format, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// validateOutputs checks that all outputs have a unique name, a main template and valid post processors.
+func validateOutputs(outputs []OutputConfig) error {
+	names := make(map[string]bool, len(outputs))
+	for i, output := range outputs {
+		if len(output.Name) == 0 {
+			return errors.WithMessagef(ErrInvalidOutputs, "output %d has no name", i+1)
+		}
+		if _, err := cleanFilePath(output.Name); err != nil {
+			return errors.WithMessagef(ErrInvalidOutputs, "output %s has an invalid name", output.Name)
+		}
+		if names[output.Name] {
+			return errors.WithMessagef(ErrInvalidOutputs, "output %s is declared twice", output.Name)
+		}
+		names[output.Name] = true
+		if len(output.MainTemplate) == 0 {
+			return errors.WithMessagef(ErrInvalidOutputs, "output %s has no main_template", output.Name)
+		}
+		if _, err := newPostProcessors(output.PostProcessors); err != nil {
+			return errors.WithMessage(err, output.Name)
+		}
+	}
+	return nil
+}
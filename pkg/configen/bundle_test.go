@@ -0,0 +1,112 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+)
+
+func TestRepository_GenerateBundle(t *testing.T) {
+	tests := []struct {
+		name           string
+		templateFolder string
+		want           *Bundle
+	}{
+		{
+			name:           "template with multiple outputs",
+			templateFolder: "g8",
+			want: &Bundle{
+				MultiFile: true,
+				Files: []*BundleFile{
+					{Name: "startup.json", Format: "json", Content: []byte(`{"hostname":"leaf1"}`)},
+					{Name: "acl.txt", Format: "txt", Content: []byte("permit 10.0.0.0/24")},
+				},
+			},
+		}, {
+			name:           "template without outputs",
+			templateFolder: "g3",
+			want: &Bundle{
+				Files: []*BundleFile{
+					{Name: "main.gojson", Content: []byte(`{"name":"Chris","static":"static"}`)},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			r := NewRepository("testdata/templates")
+			var variables map[string]interface{}
+			is.NoError(util.ReadJSONObject("testdata/templates/"+tt.templateFolder+"/variables.json", &variables))
+			got, err := r.GenerateBundle(context.Background(), tt.templateFolder, variables)
+			is.NoError(err)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRepository_GenerateFile_MultipleOutputs(t *testing.T) {
+	r := NewRepository("testdata/templates")
+	_, _, err := r.GenerateFile(context.Background(), "g8", nil)
+	require.True(t, errors.Is(err, ErrMultipleOutputs))
+}
+
+func Test_validateOutputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs []OutputConfig
+		wantErr bool
+	}{
+		{
+			name:    "valid outputs",
+			outputs: []OutputConfig{{Name: "a.txt", MainTemplate: "a.gotext"}, {Name: "b.txt", MainTemplate: "b.gotext"}},
+		}, {
+			name:    "missing name",
+			outputs: []OutputConfig{{MainTemplate: "a.gotext"}},
+			wantErr: true,
+		}, {
+			name:    "duplicate name",
+			outputs: []OutputConfig{{Name: "a.txt", MainTemplate: "a.gotext"}, {Name: "a.txt", MainTemplate: "b.gotext"}},
+			wantErr: true,
+		}, {
+			name:    "name outside of the bundle",
+			outputs: []OutputConfig{{Name: "../a.txt", MainTemplate: "a.gotext"}},
+			wantErr: true,
+		}, {
+			name:    "missing main template",
+			outputs: []OutputConfig{{Name: "a.txt"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOutputs(tt.outputs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateOutputs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
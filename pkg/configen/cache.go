@@ -0,0 +1,202 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"io/fs"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// cacheWatchInterval is the interval in which the cached templates are checked for file changes.
+const cacheWatchInterval = 2 * time.Second
+
+// CacheStats are the statistics of the parsed template cache.
+type CacheStats struct {
+	// Hits is the number of generations that used an already parsed template.
+	Hits uint64 `json:"hits"`
+	// Misses is the number of generations that had to parse the template.
+	Misses uint64 `json:"misses"`
+	// Entries is the number of currently cached templates.
+	Entries int `json:"entries"`
+}
+
+// fileStamp identifies a version of a file or folder.
+type fileStamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func stampFile(store TemplateStore, file string) fileStamp {
+	info, err := fs.Stat(store, file)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, modTime: info.ModTime(), size: info.Size()}
+}
+
+// cachedTemplate is a parsed template together with the files it was parsed from.
+type cachedTemplate struct {
+	config    *TemplateConfig
+	store     TemplateStore
+	templates ParsedTemplate
+	outputs   []*templateOutput
+	schema    *jsonSchema
+	files     map[string]fileStamp
+	// renderTimeout and maxOutputSize are the stricter limits of the repository and the template config.
+	renderTimeout time.Duration
+	maxOutputSize int64
+}
+
+// watch records the current state of the files and of the folders they are in.
+// Watching the folders detects files which are added later on.
+func (t *cachedTemplate) watch(files ...string) {
+	for _, file := range files {
+		t.files[file] = stampFile(t.store, file)
+		t.files[path.Dir(file)] = stampFile(t.store, path.Dir(file))
+	}
+}
+
+// watchPattern records the current state of all files matching the pattern.
+func (t *cachedTemplate) watchPattern(pattern string) {
+	if len(pattern) == 0 {
+		return
+	}
+	t.files[path.Dir(pattern)] = stampFile(t.store, path.Dir(pattern))
+	matches, _ := fs.Glob(t.store, pattern)
+	t.watch(matches...)
+}
+
+// isStale checks whether one of the watched files was changed.
+func (t *cachedTemplate) isStale() bool {
+	for file, stamp := range t.files {
+		current := stampFile(t.store, file)
+		if current.exists != stamp.exists || current.size != stamp.size || !current.modTime.Equal(stamp.modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateCache caches parsed templates by template folder.
+type templateCache struct {
+	// hits and misses are first to keep them 64-bit aligned for the atomic operations.
+	hits    uint64
+	misses  uint64
+	entries map[string]*cachedTemplate
+	mutex   sync.RWMutex
+	ticker  *time.Ticker
+	done    chan struct{}
+	once    sync.Once
+}
+
+// newTemplateCache creates a template cache, which removes the changed templates every interval until it is closed.
+func newTemplateCache(interval time.Duration) *templateCache {
+	c := &templateCache{
+		entries: make(map[string]*cachedTemplate),
+		ticker:  time.NewTicker(interval),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		for {
+			select {
+			case <-c.ticker.C:
+				c.invalidateStale()
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// close stops removing the changed templates, the cached templates are used until they are invalidated.
+func (c *templateCache) close() {
+	c.once.Do(func() {
+		c.ticker.Stop()
+		close(c.done)
+	})
+}
+
+// get returns the cached template of the template folder or nil.
+// It doesn't access the template store, changed templates are removed by the watcher.
+func (c *templateCache) get(templateFolder string) *cachedTemplate {
+	c.mutex.RLock()
+	entry, ok := c.entries[templateFolder]
+	c.mutex.RUnlock()
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+		return entry
+	}
+	atomic.AddUint64(&c.misses, 1)
+	return nil
+}
+
+func (c *templateCache) put(templateFolder string, entry *cachedTemplate) {
+	c.mutex.Lock()
+	c.entries[templateFolder] = entry
+	c.mutex.Unlock()
+}
+
+// invalidate removes all cached templates.
+func (c *templateCache) invalidate() {
+	c.mutex.Lock()
+	c.entries = make(map[string]*cachedTemplate)
+	c.mutex.Unlock()
+}
+
+// invalidateStale removes all cached templates whose files were changed.
+func (c *templateCache) invalidateStale() {
+	c.mutex.RLock()
+	entries := make(map[string]*cachedTemplate, len(c.entries))
+	for templateFolder, entry := range c.entries {
+		entries[templateFolder] = entry
+	}
+	c.mutex.RUnlock()
+
+	for templateFolder, entry := range entries {
+		if !entry.isStale() {
+			continue
+		}
+		c.remove(templateFolder, entry)
+	}
+}
+
+// remove removes the changed template, unless it was already replaced.
+func (c *templateCache) remove(templateFolder string, entry *cachedTemplate) {
+	log.Debug().Str("template", templateFolder).Msg("template changed, removed from cache")
+	c.mutex.Lock()
+	if c.entries[templateFolder] == entry {
+		delete(c.entries, templateFolder)
+	}
+	c.mutex.Unlock()
+}
+
+func (c *templateCache) stats() CacheStats {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&c.hits),
+		Misses:  atomic.LoadUint64(&c.misses),
+		Entries: len(c.entries),
+	}
+}
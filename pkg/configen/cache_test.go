@@ -0,0 +1,120 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"context"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_GenerateFile_Cache(t *testing.T) {
+	is := require.New(t)
+	templatePath, err := ioutil.TempDir("", "templates")
+	is.NoError(err)
+	defer func() { _ = os.RemoveAll(templatePath) }()
+	is.NoError(os.MkdirAll(filepath.Join(templatePath, "t"), 0755))
+	is.NoError(os.MkdirAll(filepath.Join(templatePath, "includes"), 0755))
+	config := "engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\ninclude_pattern: \"includes/*.gotext\"\n"
+	is.NoError(ioutil.WriteFile(filepath.Join(templatePath, "t", "config.yaml"), []byte(config), 0644))
+	is.NoError(ioutil.WriteFile(filepath.Join(templatePath, "t", "main.gotext"), []byte(`{{template "footer.gotext"}}`), 0644))
+	is.NoError(ioutil.WriteFile(filepath.Join(templatePath, "includes", "footer.gotext"), []byte("v1"), 0644))
+
+	r := NewRepository(templatePath)
+	for i := 0; i < 3; i++ {
+		got, _, err := r.GenerateFile(context.Background(), "t", nil)
+		is.NoError(err)
+		is.Equal("v1", string(got))
+	}
+	is.Equal(CacheStats{Hits: 2, Misses: 1, Entries: 1}, r.CacheStats())
+
+	r.cache.invalidateStale()
+	is.Equal(1, r.CacheStats().Entries)
+
+	// Changes of include files invalidate the cached template.
+	is.NoError(ioutil.WriteFile(filepath.Join(templatePath, "includes", "footer.gotext"), []byte("v2"), 0644))
+	is.NoError(os.Chtimes(filepath.Join(templatePath, "includes", "footer.gotext"), time.Now(), time.Now().Add(time.Second)))
+	r.cache.invalidateStale()
+	is.Equal(0, r.CacheStats().Entries)
+	got, _, err := r.GenerateFile(context.Background(), "t", nil)
+	is.NoError(err)
+	is.Equal("v2", string(got))
+
+	// New files in the template folder invalidate the cached template.
+	is.NoError(ioutil.WriteFile(filepath.Join(templatePath, "t", "other.gotext"), []byte(""), 0644))
+	is.NoError(os.Chtimes(filepath.Join(templatePath, "t"), time.Now(), time.Now().Add(2*time.Second)))
+	r.cache.invalidateStale()
+	is.Equal(CacheStats{Hits: 2, Misses: 2, Entries: 0}, r.CacheStats())
+
+	r.Close()
+	r.Close()
+}
+
+// countingStore counts the accesses of the template store.
+type countingStore struct {
+	TemplateStore
+	accesses int64
+}
+
+func (s *countingStore) Open(name string) (fs.File, error) {
+	atomic.AddInt64(&s.accesses, 1)
+	return s.TemplateStore.Open(name)
+}
+
+func (s *countingStore) Stat(name string) (fs.FileInfo, error) {
+	atomic.AddInt64(&s.accesses, 1)
+	return fs.Stat(s.TemplateStore, name)
+}
+
+func TestRepository_GenerateFile_CacheHit(t *testing.T) {
+	is := require.New(t)
+	store := &countingStore{TemplateStore: NewMemoryStore(map[string][]byte{
+		"t/config.yaml": []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n"),
+		"t/main.gotext": []byte("v1"),
+	})}
+	r := NewStoreRepository(store)
+	defer r.Close()
+	_, _, err := r.GenerateFile(context.Background(), "t", nil)
+	is.NoError(err)
+	accesses := atomic.LoadInt64(&store.accesses)
+	is.NotZero(accesses)
+
+	// Cache hits don't access the store, only the watcher checks the files for changes.
+	got, _, err := r.GenerateFile(context.Background(), "t", nil)
+	is.NoError(err)
+	is.Equal("v1", string(got))
+	is.Equal(accesses, atomic.LoadInt64(&store.accesses))
+	is.Equal(CacheStats{Hits: 1, Misses: 1, Entries: 1}, r.CacheStats())
+}
+
+func Test_templateCache_close(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	c := newTemplateCache(time.Millisecond)
+	c.close()
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	require.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+}
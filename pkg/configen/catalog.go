@@ -0,0 +1,129 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// Templates returns all templates of the template folder.
+// Each folder containing a config.yaml is treated as template, each version of a versioned template is listed.
+func (r *Repository) Templates() ([]*TemplateInfo, error) {
+	result := make([]*TemplateInfo, 0)
+	err := fs.WalkDir(r.store, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != "." && strings.HasPrefix(entry.Name(), ".") {
+			return fs.SkipDir
+		}
+		if !isTemplateFolder(r.store, path) {
+			return nil
+		}
+		result = append(result, r.templateInfo(path))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return versionLess(result[i].Version, result[j].Version)
+	})
+	return result, nil
+}
+
+// Template returns the template with the given name.
+// Versioned templates are returned in the highest version matching the version constraint.
+func (r *Repository) Template(templateName string, constraint string) (*TemplateInfo, error) {
+	templateFolder, _, err := r.ResolveTemplate(templateName, constraint)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := readConfigFile(r.store, templateFolder); err != nil {
+		return nil, err
+	}
+	return r.templateInfo(templateFolder), nil
+}
+
+func (r *Repository) templateInfo(templateName string) *TemplateInfo {
+	info := &TemplateInfo{}
+	info.Name, info.Version = splitVersion(r.store, templateName)
+	config, err := readConfigFile(r.store, templateName)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.Config = config
+	resolved, err := parseConfigFile(r.store, templateName)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	if info.MainFiles, err = r.glob(resolved.MainPattern); err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	if info.IncludeFiles, err = r.glob(resolved.IncludePattern); err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	for _, pattern := range resolved.inheritedPatterns {
+		files, err := r.glob(pattern)
+		if err != nil {
+			info.Error = err.Error()
+			return info
+		}
+		info.InheritedFiles = append(info.InheritedFiles, files...)
+	}
+	return info
+}
+
+// glob returns the files of the template store matching the pattern.
+func (r *Repository) glob(pattern string) ([]string, error) {
+	if len(pattern) == 0 {
+		return nil, nil
+	}
+	files, err := fs.Glob(r.store, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if files == nil {
+		files = []string{}
+	}
+	return files, nil
+}
+
+// isValidTemplateName checks that the template name stays inside the template folder.
+func isValidTemplateName(templateName string) bool {
+	if len(templateName) == 0 {
+		return false
+	}
+	for _, segment := range strings.Split(templateName, "/") {
+		if len(segment) == 0 || strings.HasPrefix(segment, ".") || strings.Contains(segment, "\\") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_Templates(t *testing.T) {
+	is := require.New(t)
+	r := NewRepository("testdata/templates")
+	templates, err := r.Templates()
+	is.NoError(err)
+	names := make([]string, 0, len(templates))
+	for _, template := range templates {
+		names = append(names, template.Name)
+	}
+	is.Subset(names, []string{"g1", "g2", "g3", "g4", "t1", "t2"})
+	is.NotContains(names, "includes")
+	is.True(sort.StringsAreSorted(names))
+
+	versions := make([]string, 0)
+	for _, template := range templates {
+		if template.Name == "v1" {
+			versions = append(versions, template.Version)
+		}
+	}
+	is.Equal([]string{"1.0.0", "1.2.0", "1.10.0"}, versions)
+}
+
+func TestRepository_Template(t *testing.T) {
+	tests := []struct {
+		name         string
+		templateName string
+		want         *TemplateInfo
+		wantedErr    error
+	}{
+		{
+			templateName: "g2",
+			want: &TemplateInfo{
+				Name: "g2",
+				Config: &TemplateConfig{
+					TemplateEngine: "golang",
+					MainPattern:    "*.gotext",
+					IncludePattern: "includes/*.gotext",
+					MainTemplate:   "main.gotext",
+				},
+				MainFiles:    []string{"g2/main.gotext"},
+				IncludeFiles: []string{"includes/footer.gotext"},
+			},
+		}, {
+			templateName: "v1",
+			want: &TemplateInfo{
+				Name:    "v1",
+				Version: "1.10.0",
+				Config: &TemplateConfig{
+					MainPattern:  "*.gotext",
+					MainTemplate: "main.gotext",
+				},
+				MainFiles: []string{"v1/1.10.0/main.gotext"},
+			},
+		}, {
+			templateName: "g0",
+			wantedErr:    ErrTemplateConfigNotFound,
+		}, {
+			templateName: "../templates",
+			wantedErr:    ErrInvalidTemplateName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRepository("testdata/templates")
+			got, err := r.Template(tt.templateName, "")
+			if tt.wantedErr != nil {
+				if !errors.Is(err, tt.wantedErr) {
+					t.Errorf("Template() error = %v, wantedErr %v", err, tt.wantedErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(TemplateConfig{})); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
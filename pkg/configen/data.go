@@ -0,0 +1,144 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/yosuke-furukawa/json5/encoding/json5"
+	"gopkg.in/yaml.v3"
+)
+
+// dataFolder is the name of the optional folder with the data files, either in the template path or in a template folder.
+const dataFolder = "data"
+
+// dataFolders returns the data folders of a template, the data folder of the template path and the top most parent first.
+func dataFolders(templateFolder string, parents []string) []string {
+	folders := []string{dataFolder}
+	for i := len(parents) - 1; i >= 0; i-- {
+		folders = append(folders, fmt.Sprintf("%s/%s", parents[i], dataFolder))
+	}
+	return append(folders, fmt.Sprintf("%s/%s", templateFolder, dataFolder))
+}
+
+// readData reads the data files of the data folders.
+// A data file replaces the data file with the same name of an earlier folder.
+func readData(store TemplateStore, folders []string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	for _, folder := range folders {
+		folderData, err := readDataFolder(store, folder)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range folderData {
+			data[name] = value
+		}
+	}
+	return data, nil
+}
+
+// readDataFolder reads the yaml, json and json5 files of the folder by their name without extension.
+// A missing folder results in no data.
+func readDataFolder(store TemplateStore, folder string) (map[string]interface{}, error) {
+	files, err := fs.ReadDir(store, folder)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{})
+	for _, file := range files {
+		extension := path.Ext(file.Name())
+		name := strings.TrimSuffix(file.Name(), extension)
+		if file.IsDir() || len(name) == 0 {
+			continue
+		}
+		var unmarshal func([]byte, interface{}) error
+		switch extension {
+		case ".yaml", ".yml":
+			unmarshal = yaml.Unmarshal
+		case ".json":
+			unmarshal = json.Unmarshal
+		case ".json5":
+			unmarshal = json5.Unmarshal
+		default:
+			continue
+		}
+		file := path.Join(folder, file.Name())
+		if _, ok := data[name]; ok {
+			return nil, errors.WithMessagef(ErrInvalidData, "%s: data %s is defined twice", file, name)
+		}
+		content, err := fs.ReadFile(store, file)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err := unmarshal(content, &value); err != nil {
+			return nil, errors.WithMessagef(ErrInvalidData, "%s: %v", file, err)
+		}
+		data[name] = value
+	}
+	return data, nil
+}
+
+// dataLookup returns the lookup function of the template data.
+// e.g. lookup "ports" .platform "uplinks" returns the uplinks of the platform in the ports data file.
+// Missing keys result in nil, so that templates can fall back to a default.
+func dataLookup(data map[string]interface{}) func(name string, keys ...interface{}) (interface{}, error) {
+	return func(name string, keys ...interface{}) (interface{}, error) {
+		value, ok := data[name]
+		if !ok {
+			return nil, errors.WithMessage(ErrDataNotFound, name)
+		}
+		for _, key := range keys {
+			if value = lookupKey(value, key); value == nil {
+				return nil, nil
+			}
+		}
+		return value, nil
+	}
+}
+
+// lookupKey returns the value of the key in a map or of the index in a list, nil if there is none.
+func lookupKey(value interface{}, key interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v[fmt.Sprint(key)]
+	case map[interface{}]interface{}:
+		if item, ok := v[key]; ok {
+			return item
+		}
+		// Keys of the variables and of the data files may differ in their type, e.g. 1 and "1".
+		for k, item := range v {
+			if fmt.Sprint(k) == fmt.Sprint(key) {
+				return item
+			}
+		}
+	case []interface{}:
+		index, err := toInt64(key)
+		if err != nil || index < 0 || index >= int64(len(v)) {
+			return nil
+		}
+		return v[index]
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_GenerateFileData(t *testing.T) {
+	is := require.New(t)
+	templatePath, err := ioutil.TempDir("", "templates")
+	is.NoError(err)
+	defer func() { _ = os.RemoveAll(templatePath) }()
+	write := func(file string, content string) {
+		is.NoError(os.MkdirAll(filepath.Dir(filepath.Join(templatePath, file)), 0755))
+		is.NoError(ioutil.WriteFile(filepath.Join(templatePath, file), []byte(content), 0644))
+	}
+	write("data/ports.yaml", "q2c:\n  uplinks: [ifp_0/0/1, ifp_0/0/2]\n")
+	write("data/qos.json", `{"gold": {"priority": 5}}`)
+	write("base/config.yaml", "main_pattern: \"*.gotext\"\nmain_template: main.gotext\n")
+	write("base/data/qos.json5", "{gold: {priority: 6}, silver: {priority: 3}}")
+	write("base/main.gotext", `{{range lookup "ports" .platform "uplinks"}}{{.}} {{end}}{{lookup "qos" "gold" "priority"}} {{lookup "qos" "bronze" | default "none"}}`)
+	write("child/config.yaml", "extends: base\n")
+	write("child/data/qos.yml", "gold:\n  priority: 7\n")
+
+	r := NewRepository(templatePath)
+	variables := map[string]interface{}{"platform": "q2c"}
+	got, _, err := r.GenerateFile(context.Background(), "base", variables)
+	is.NoError(err)
+	is.Equal("ifp_0/0/1 ifp_0/0/2 6 none", string(got))
+	got, _, err = r.GenerateFile(context.Background(), "child", variables)
+	is.NoError(err)
+	is.Equal("ifp_0/0/1 ifp_0/0/2 7 none", string(got))
+
+	// Changes of the data files invalidate the cached template.
+	write("data/ports.yaml", "q2c:\n  uplinks: [ifp_0/1/1]\n")
+	is.NoError(os.Chtimes(filepath.Join(templatePath, "data", "ports.yaml"), time.Now(), time.Now().Add(time.Second)))
+	r.cache.invalidateStale()
+	got, _, err = r.GenerateFile(context.Background(), "child", variables)
+	is.NoError(err)
+	is.Equal("ifp_0/1/1 7 none", string(got))
+
+	write("base/main.gotext", `{{lookup "unknown"}}`)
+	_, _, err = NewRepository(templatePath).GenerateFile(context.Background(), "base", variables)
+	is.Error(err)
+	is.Contains(err.Error(), ErrDataNotFound.Error())
+
+	write("base/data/qos.json", "{}")
+	_, _, err = NewRepository(templatePath).GenerateFile(context.Background(), "base", variables)
+	is.True(errors.Is(err, ErrInvalidData))
+}
+
+func TestLookupKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		key   interface{}
+		want  interface{}
+	}{
+		{name: "map", value: map[string]interface{}{"a": 1}, key: "a", want: 1},
+		{name: "map missing", value: map[string]interface{}{"a": 1}, key: "b", want: nil},
+		{name: "map number key", value: map[string]interface{}{"1": "x"}, key: 1, want: "x"},
+		{name: "yaml number key", value: map[interface{}]interface{}{1: "x"}, key: "1", want: "x"},
+		{name: "list", value: []interface{}{"x", "y"}, key: 1, want: "y"},
+		{name: "list json index", value: []interface{}{"x", "y"}, key: float64(0), want: "x"},
+		{name: "list out of range", value: []interface{}{"x"}, key: 1, want: nil},
+		{name: "scalar", value: "x", key: "a", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, lookupKey(tt.value, tt.key))
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultsFile is the name of the optional file with the default variables of a template.
+const defaultsFile = "defaults.yaml"
+
+// ListMerge is the policy to merge lists of the default variables with lists of the request variables.
+type ListMerge string
+
+const (
+	// ListMergeReplace replaces default lists by request lists
+	ListMergeReplace ListMerge = "replace"
+	// ListMergeAppend appends request lists to default lists
+	ListMergeAppend ListMerge = "append"
+)
+
+// EffectiveVariables returns the variables of the template folder merged over the default variables of the template.
+func (r *Repository) EffectiveVariables(templateFolder string, variables map[string]interface{}) (map[string]interface{}, error) {
+	template, err := r.getTemplate(templateFolder)
+	if err != nil {
+		return nil, err
+	}
+	return template.effectiveVariables(variables), nil
+}
+
+// effectiveVariables merges the variables over the default variables of the template.
+func (t *cachedTemplate) effectiveVariables(variables map[string]interface{}) map[string]interface{} {
+	if len(t.config.Defaults) == 0 {
+		return variables
+	}
+	return mergeVariables(t.config.Defaults, variables, t.config.ListMerge)
+}
+
+// readDefaultsFile reads the defaults.yaml of the template folder, a missing file results in no defaults.
+func readDefaultsFile(store TemplateStore, templateFolder string) (map[string]interface{}, error) {
+	file := fmt.Sprintf("%s/%s", templateFolder, defaultsFile)
+	content, err := fs.ReadFile(store, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defaults := make(map[string]interface{})
+	if err := yaml.Unmarshal(content, &defaults); err != nil {
+		return nil, errors.WithMessagef(ErrInvalidDefaults, "%s: %v", file, err)
+	}
+	return defaults, nil
+}
+
+// validateListMerge checks that the list merge policy is known.
+func validateListMerge(listMerge ListMerge) error {
+	switch listMerge {
+	case "", ListMergeReplace, ListMergeAppend:
+		return nil
+	}
+	return errors.WithMessagef(ErrInvalidDefaults, "unknown list_merge %s", listMerge)
+}
+
+// mergeVariables deep merges the variables over the defaults without modifying one of them.
+// Maps are merged key by key, lists are merged according to the list merge policy and all other values replace the default.
+func mergeVariables(defaults map[string]interface{}, variables map[string]interface{}, listMerge ListMerge) map[string]interface{} {
+	result := make(map[string]interface{}, len(defaults)+len(variables))
+	for key, value := range defaults {
+		result[key] = copyValue(value)
+	}
+	for key, value := range variables {
+		result[key] = mergeValue(result[key], value, listMerge)
+	}
+	return result
+}
+
+func mergeValue(defaultValue interface{}, value interface{}, listMerge ListMerge) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if d, ok := defaultValue.(map[string]interface{}); ok {
+			return mergeVariables(d, v, listMerge)
+		}
+	case []interface{}:
+		if d, ok := defaultValue.([]interface{}); ok && listMerge == ListMergeAppend {
+			return append(d, v...)
+		}
+	}
+	return value
+}
+
+// copyValue copies maps and lists, so that merging never modifies the defaults of a template.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			result[key] = copyValue(value)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, value := range v {
+			result[i] = copyValue(value)
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mergeVariables(t *testing.T) {
+	tests := []struct {
+		name      string
+		defaults  map[string]interface{}
+		variables map[string]interface{}
+		listMerge ListMerge
+		want      map[string]interface{}
+	}{
+		{
+			name:      "no defaults",
+			variables: map[string]interface{}{"a": 1.0},
+			want:      map[string]interface{}{"a": 1.0},
+		}, {
+			name:     "no variables",
+			defaults: map[string]interface{}{"a": 1},
+			want:     map[string]interface{}{"a": 1},
+		}, {
+			name:      "variables override defaults",
+			defaults:  map[string]interface{}{"a": 1, "b": "default"},
+			variables: map[string]interface{}{"b": "request"},
+			want:      map[string]interface{}{"a": 1, "b": "request"},
+		}, {
+			name:      "deep merge maps",
+			defaults:  map[string]interface{}{"ntp": map[string]interface{}{"prefer": true, "server": "a"}},
+			variables: map[string]interface{}{"ntp": map[string]interface{}{"server": "b"}},
+			want:      map[string]interface{}{"ntp": map[string]interface{}{"prefer": true, "server": "b"}},
+		}, {
+			name:      "map replaces scalar",
+			defaults:  map[string]interface{}{"ntp": "none"},
+			variables: map[string]interface{}{"ntp": map[string]interface{}{"server": "b"}},
+			want:      map[string]interface{}{"ntp": map[string]interface{}{"server": "b"}},
+		}, {
+			name:      "replace lists",
+			defaults:  map[string]interface{}{"l": []interface{}{"a"}},
+			variables: map[string]interface{}{"l": []interface{}{"b"}},
+			listMerge: ListMergeReplace,
+			want:      map[string]interface{}{"l": []interface{}{"b"}},
+		}, {
+			name:      "replace lists by default",
+			defaults:  map[string]interface{}{"l": []interface{}{"a"}},
+			variables: map[string]interface{}{"l": []interface{}{"b"}},
+			want:      map[string]interface{}{"l": []interface{}{"b"}},
+		}, {
+			name:      "append lists",
+			defaults:  map[string]interface{}{"m": map[string]interface{}{"l": []interface{}{"a"}}},
+			variables: map[string]interface{}{"m": map[string]interface{}{"l": []interface{}{"b"}}},
+			listMerge: ListMergeAppend,
+			want:      map[string]interface{}{"m": map[string]interface{}{"l": []interface{}{"a", "b"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeVariables(tt.defaults, tt.variables, tt.listMerge)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_mergeVariablesKeepsDefaults(t *testing.T) {
+	defaults := map[string]interface{}{"m": map[string]interface{}{"l": []interface{}{"a"}}}
+	_ = mergeVariables(defaults, map[string]interface{}{"m": map[string]interface{}{"l": []interface{}{"b"}, "x": 1}}, ListMergeAppend)
+	if diff := cmp.Diff(map[string]interface{}{"m": map[string]interface{}{"l": []interface{}{"a"}}}, defaults); diff != "" {
+		t.Errorf("defaults modified (-want +got):\n%s", diff)
+	}
+}
+
+func TestRepository_EffectiveVariables(t *testing.T) {
+	is := require.New(t)
+	r := NewRepository("testdata/templates")
+	got, err := r.EffectiveVariables("g11", map[string]interface{}{"site": "muc1"})
+	is.NoError(err)
+	want := map[string]interface{}{
+		"site": "muc1",
+		"ntp":  map[string]interface{}{"servers": []interface{}{"10.0.0.1"}, "prefer": true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
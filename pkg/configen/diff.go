@@ -0,0 +1,332 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/yosuke-furukawa/json5/encoding/json5"
+)
+
+const (
+	// diffContextLines is the number of unchanged lines shown around the changes of a unified diff.
+	diffContextLines = 3
+	// maxDiffLines limits the lines of the files which are compared.
+	maxDiffLines = 10000
+)
+
+// Kinds of the changes of a structural diff
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// DiffSide selects the template folder and the variables rendering one side of a diff.
+type DiffSide struct {
+	// TemplateFolder is the folder of the template, including the version of versioned templates
+	TemplateFolder string
+	// Variables for the generation
+	Variables map[string]interface{}
+}
+
+// RenderDiff is the difference between the files generated for the current and the candidate side.
+// Templates with multiple outputs are compared file by file, the files are matched by their output name.
+type RenderDiff struct {
+	// Name of the output, only set for the files of templates with multiple outputs
+	Name string `json:"name,omitempty"`
+	// Format of the generated files, the format of the candidate if the formats differ
+	Format string `json:"format"`
+	// Equal reports whether both files are equal, json and json5 files are compared as objects
+	Equal bool `json:"equal"`
+	// Diff is the unified diff of the files, empty if the files are equal
+	Diff string `json:"diff"`
+	// Changes are the changed values of json and json5 files
+	Changes []Change `json:"changes,omitempty"`
+	// Files are the differences of each file of templates with multiple outputs, the diff covers all files then
+	Files []*RenderDiff `json:"files,omitempty"`
+}
+
+// Change is a changed value of a structural diff.
+type Change struct {
+	// Path is the JSON pointer to the changed value (e.g. /interfaces/0/name).
+	Path string `json:"path"`
+	// Kind of the change, added, removed or changed
+	Kind string `json:"kind"`
+	// Current is the value of the current file, not set for added values
+	Current interface{} `json:"current,omitempty"`
+	// Candidate is the value of the candidate file, not set for removed values
+	Candidate interface{} `json:"candidate,omitempty"`
+}
+
+// DiffFiles renders the current and the candidate side and returns the difference of the generated files.
+// The sides either differ by their variables or by their template folder.
+func (r *Repository) DiffFiles(ctx context.Context, current DiffSide, candidate DiffSide) (*RenderDiff, error) {
+	currentBundle, err := r.GenerateBundle(ctx, current.TemplateFolder, current.Variables)
+	if err == nil {
+		err = checkDiffLines(currentBundle)
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "current")
+	}
+	candidateBundle, err := r.GenerateBundle(ctx, candidate.TemplateFolder, candidate.Variables)
+	if err == nil {
+		err = checkDiffLines(candidateBundle)
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "candidate")
+	}
+	if !currentBundle.MultiFile && !candidateBundle.MultiFile {
+		return diffFile("current/"+current.TemplateFolder, "candidate/"+candidate.TemplateFolder,
+			currentBundle.Files[0], candidateBundle.Files[0]), nil
+	}
+	currentFiles := bundleFiles(currentBundle)
+	candidateFiles := bundleFiles(candidateBundle)
+	names := make([]string, 0, len(currentFiles)+len(candidateFiles))
+	for name := range currentFiles {
+		names = append(names, name)
+	}
+	for name := range candidateFiles {
+		if _, ok := currentFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	result := &RenderDiff{Equal: true}
+	var diff strings.Builder
+	for _, name := range names {
+		fileDiff := diffFile(path.Join("current", current.TemplateFolder, name), path.Join("candidate", candidate.TemplateFolder, name),
+			currentFiles[name], candidateFiles[name])
+		fileDiff.Name = name
+		result.Equal = result.Equal && fileDiff.Equal
+		diff.WriteString(fileDiff.Diff)
+		result.Files = append(result.Files, fileDiff)
+	}
+	result.Diff = diff.String()
+	return result, nil
+}
+
+// checkDiffLines rejects files with more lines than are compared.
+func checkDiffLines(bundle *Bundle) error {
+	for _, file := range bundle.Files {
+		if bytes.Count(file.Content, []byte("\n")) >= maxDiffLines {
+			return errors.WithMessagef(ErrOutputTooLarge, "%s has more than %d lines to compare", file.Name, maxDiffLines)
+		}
+	}
+	return nil
+}
+
+// bundleFiles maps the output names to the files of the bundle.
+func bundleFiles(bundle *Bundle) map[string]*BundleFile {
+	files := make(map[string]*BundleFile, len(bundle.Files))
+	for _, file := range bundle.Files {
+		files[file.Name] = file
+	}
+	return files
+}
+
+// diffFile returns the difference of the current and the candidate file, a nil file was not generated by that side.
+func diffFile(currentName string, candidateName string, current *BundleFile, candidate *BundleFile) *RenderDiff {
+	missing := &BundleFile{}
+	if current == nil {
+		current = missing
+	}
+	if candidate == nil {
+		candidate = missing
+	}
+	result := &RenderDiff{
+		Format: candidate.Format,
+		Equal:  bytes.Equal(current.Content, candidate.Content) && (current == missing) == (candidate == missing),
+		Diff:   unifiedDiff(currentName, candidateName, string(current.Content), string(candidate.Content)),
+	}
+	if candidate == missing {
+		result.Format = current.Format
+	}
+	if current == missing || candidate == missing || current.Format != candidate.Format ||
+		(candidate.Format != "json" && candidate.Format != "json5") {
+		return result
+	}
+	currentObject, err := parseOutputObject(current.Format, current.Content)
+	if err != nil {
+		return result
+	}
+	candidateObject, err := parseOutputObject(candidate.Format, candidate.Content)
+	if err != nil {
+		return result
+	}
+	result.Changes = diffObjects("", currentObject, candidateObject, nil)
+	result.Equal = len(result.Changes) == 0
+	return result
+}
+
+// NormalizeOutput parses json and json5 outputs, so that outputs can be compared independent of their formatting.
+// Outputs of other formats and unparseable outputs are returned as string.
+func NormalizeOutput(format string, output []byte) interface{} {
+	v, err := parseOutputObject(format, output)
+	if err != nil {
+		return fmt.Sprintf("not parseable (%s)", output) // use unparseable input as the output
+	}
+	return v
+}
+
+// parseOutputObject parses json and json5 outputs, outputs of other formats are returned as string.
+func parseOutputObject(format string, output []byte) (interface{}, error) {
+	var v interface{}
+	switch format {
+	case "json":
+		if err := json.Unmarshal(output, &v); err != nil {
+			return nil, err
+		}
+	case "json5":
+		if err := json5.Unmarshal(output, &v); err != nil {
+			return nil, err
+		}
+	default:
+		return string(output), nil
+	}
+	return v, nil
+}
+
+// diffObjects appends the changes between the current and the candidate value at the path.
+// Objects are compared by their keys and arrays by the index of their elements.
+func diffObjects(path string, current interface{}, candidate interface{}, changes []Change) []Change {
+	switch c := current.(type) {
+	case map[string]interface{}:
+		if m, ok := candidate.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(c)+len(m))
+			for key := range c {
+				keys = append(keys, key)
+			}
+			for key := range m {
+				if _, ok := c[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				childPath := path + "/" + escapePointer(key)
+				currentValue, inCurrent := c[key]
+				candidateValue, inCandidate := m[key]
+				switch {
+				case !inCandidate:
+					changes = append(changes, Change{Path: childPath, Kind: ChangeRemoved, Current: currentValue})
+				case !inCurrent:
+					changes = append(changes, Change{Path: childPath, Kind: ChangeAdded, Candidate: candidateValue})
+				default:
+					changes = diffObjects(childPath, currentValue, candidateValue, changes)
+				}
+			}
+			return changes
+		}
+	case []interface{}:
+		if l, ok := candidate.([]interface{}); ok {
+			for i := 0; i < len(c) || i < len(l); i++ {
+				childPath := fmt.Sprintf("%s/%d", path, i)
+				switch {
+				case i >= len(l):
+					changes = append(changes, Change{Path: childPath, Kind: ChangeRemoved, Current: c[i]})
+				case i >= len(c):
+					changes = append(changes, Change{Path: childPath, Kind: ChangeAdded, Candidate: l[i]})
+				default:
+					changes = diffObjects(childPath, c[i], l[i], changes)
+				}
+			}
+			return changes
+		}
+	}
+	if !reflect.DeepEqual(current, candidate) {
+		changes = append(changes, Change{Path: path, Kind: ChangeChanged, Current: current, Candidate: candidate})
+	}
+	return changes
+}
+
+// unifiedDiff returns the unified diff of the texts, empty if the texts are equal.
+func unifiedDiff(currentName string, candidateName string, current string, candidate string) string {
+	if current == candidate {
+		return ""
+	}
+	currentLines, currentNewline := splitLines(current)
+	candidateLines, candidateNewline := splitLines(candidate)
+	matcher := difflib.NewMatcher(compareLines(currentLines, currentNewline), compareLines(candidateLines, candidateNewline))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", currentName, candidateName)
+	// writeLine writes the line, the "No newline" marker follows the last line of a text without newline.
+	writeLine := func(kind byte, text string, lastCurrent bool, lastCandidate bool) {
+		fmt.Fprintf(&b, "%c%s\n", kind, text)
+		if (lastCurrent && !currentNewline) || (lastCandidate && !candidateNewline) {
+			b.WriteString("\\ No newline at end of file\n")
+		}
+	}
+	for _, hunk := range matcher.GetGroupedOpCodes(diffContextLines) {
+		first, last := hunk[0], hunk[len(hunk)-1]
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(first.I1+1, last.I2-first.I1), hunkRange(first.J1+1, last.J2-first.J1))
+		for _, op := range hunk {
+			if op.Tag == 'e' {
+				for i, j := op.I1, op.J1; i < op.I2; i, j = i+1, j+1 {
+					writeLine(' ', currentLines[i], i == len(currentLines)-1, j == len(candidateLines)-1)
+				}
+				continue
+			}
+			for i := op.I1; i < op.I2; i++ {
+				writeLine('-', currentLines[i], i == len(currentLines)-1, false)
+			}
+			for j := op.J1; j < op.J2; j++ {
+				writeLine('+', candidateLines[j], false, j == len(candidateLines)-1)
+			}
+		}
+	}
+	return b.String()
+}
+
+// hunkRange returns the line range of a hunk, empty ranges start at the line before the hunk.
+func hunkRange(line int, length int) string {
+	if length == 0 {
+		line--
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, length)
+}
+
+// splitLines splits the text into lines and reports whether the last line ends with a newline.
+func splitLines(text string) ([]string, bool) {
+	if text == "" {
+		return nil, true
+	}
+	newline := strings.HasSuffix(text, "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n"), newline
+}
+
+// compareLines returns the lines to compare, the last line of a text without newline differs from the same line with newline.
+func compareLines(lines []string, newline bool) []string {
+	if newline || len(lines) == 0 {
+		return lines
+	}
+	compared := append([]string{}, lines...)
+	compared[len(compared)-1] += "\n"
+	return compared
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_DiffFiles(t *testing.T) {
+	variables := map[string]interface{}{"hostname": "leaf1", "interfaces": []interface{}{"ifp-0/0/1", "ifp-0/0/2"}}
+	tests := []struct {
+		name      string
+		current   DiffSide
+		candidate DiffSide
+		want      *RenderDiff
+	}{
+		{
+			name:      "equal",
+			current:   DiffSide{TemplateFolder: "x1", Variables: variables},
+			candidate: DiffSide{TemplateFolder: "x1", Variables: variables},
+			want:      &RenderDiff{Format: "json", Equal: true},
+		},
+		{
+			name:    "variables",
+			current: DiffSide{TemplateFolder: "x1", Variables: variables},
+			candidate: DiffSide{TemplateFolder: "x1", Variables: map[string]interface{}{
+				"hostname": "leaf2", "interfaces": []interface{}{"ifp-0/0/1", "ifp-0/0/2", "ifp-0/0/3"}}},
+			want: &RenderDiff{
+				Format: "json",
+				Diff: "--- current/x1\n+++ candidate/x1\n" +
+					"@@ -1,5 +1,5 @@\n" +
+					" {\n" +
+					"-  \"hostname\": \"leaf1\",\n" +
+					"-  \"interfaces\": [\"ifp-0/0/1\", \"ifp-0/0/2\"],\n" +
+					"+  \"hostname\": \"leaf2\",\n" +
+					"+  \"interfaces\": [\"ifp-0/0/1\", \"ifp-0/0/2\", \"ifp-0/0/3\"],\n" +
+					"   \"ntp\": \"10.0.0.1\"\n" +
+					" }\n",
+				Changes: []Change{
+					{Path: "/hostname", Kind: ChangeChanged, Current: "leaf1", Candidate: "leaf2"},
+					{Path: "/interfaces/2", Kind: ChangeAdded, Candidate: "ifp-0/0/3"},
+				},
+			},
+		},
+		{
+			name:      "template versions",
+			current:   DiffSide{TemplateFolder: "v1/1.0.0"},
+			candidate: DiffSide{TemplateFolder: "v1/1.2.0"},
+			want: &RenderDiff{
+				Diff: "--- current/v1/1.0.0\n+++ candidate/v1/1.2.0\n" +
+					"@@ -1 +1 @@\n" +
+					"-version 1.0.0\n" +
+					"\\ No newline at end of file\n" +
+					"+version 1.2.0\n" +
+					"\\ No newline at end of file\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			r := NewRepository("testdata/templates")
+			got, err := r.DiffFiles(context.Background(), tt.current, tt.candidate)
+			is.NoError(err)
+			is.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestRepository_DiffFilesMultipleOutputs(t *testing.T) {
+	is := require.New(t)
+	r := NewRepository("testdata/templates")
+	got, err := r.DiffFiles(context.Background(),
+		DiffSide{TemplateFolder: "g8", Variables: map[string]interface{}{"hostname": "leaf1", "network": "10.0.0.0/24"}},
+		DiffSide{TemplateFolder: "g8", Variables: map[string]interface{}{"hostname": "leaf1", "network": "10.0.1.0/24"}})
+	is.NoError(err)
+	aclDiff := "--- current/g8/acl.txt\n+++ candidate/g8/acl.txt\n" +
+		"@@ -1 +1 @@\n" +
+		"-permit 10.0.0.0/24\n" +
+		"\\ No newline at end of file\n" +
+		"+permit 10.0.1.0/24\n" +
+		"\\ No newline at end of file\n"
+	is.Equal(&RenderDiff{
+		Diff: aclDiff,
+		Files: []*RenderDiff{
+			{Name: "acl.txt", Format: "txt", Diff: aclDiff},
+			{Name: "startup.json", Format: "json", Equal: true},
+		},
+	}, got)
+}
+
+func TestRepository_DiffFilesErrors(t *testing.T) {
+	is := require.New(t)
+	r := NewRepository("testdata/templates")
+	_, err := r.DiffFiles(context.Background(), DiffSide{TemplateFolder: "x1"}, DiffSide{TemplateFolder: "missing"})
+	is.True(errors.Is(err, ErrTemplateConfigNotFound))
+	is.Contains(err.Error(), "candidate")
+}
+
+func TestRepository_DiffFilesTooLarge(t *testing.T) {
+	is := require.New(t)
+	r := NewStoreRepository(NewMemoryStore(map[string][]byte{
+		"lines/config.yaml": []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n"),
+		"lines/main.gotext": []byte(`{{range until .lines}}{{.}}{{"\n"}}{{end}}`),
+	}))
+	side := DiffSide{TemplateFolder: "lines", Variables: map[string]interface{}{"lines": maxDiffLines - 1}}
+	got, err := r.DiffFiles(context.Background(), side, side)
+	is.NoError(err)
+	is.True(got.Equal)
+	_, err = r.DiffFiles(context.Background(), side, DiffSide{TemplateFolder: "lines", Variables: map[string]interface{}{"lines": maxDiffLines}})
+	is.True(errors.Is(err, ErrOutputTooLarge))
+	is.Contains(err.Error(), "candidate")
+}
+
+func Test_unifiedDiffChangedLines(t *testing.T) {
+	// All lines change, the diff has to be computed without a quadratic trace of the edit steps.
+	current := make([]string, maxDiffLines-1)
+	candidate := make([]string, maxDiffLines-1)
+	for i := range current {
+		current[i] = fmt.Sprintf("current %d", i)
+		candidate[i] = fmt.Sprintf("candidate %d", i)
+	}
+	got := unifiedDiff("a", "b", strings.Join(current, "\n")+"\n", strings.Join(candidate, "\n")+"\n")
+	require.Equal(t, 2*(maxDiffLines-1)+3, strings.Count(got, "\n"))
+}
+
+func Test_unifiedDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   string
+		candidate string
+		want      string
+	}{
+		{
+			name:      "equal",
+			current:   "a\nb\n",
+			candidate: "a\nb\n",
+			want:      "",
+		},
+		{
+			name:      "added to empty",
+			current:   "",
+			candidate: "a\n",
+			want:      "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name:      "separate hunks",
+			current:   "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			candidate: "1\nzwei\n3\n4\n5\n6\n7\n8\n9\n10\n12\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+zwei\n 3\n 4\n 5\n" +
+				"@@ -8,5 +8,4 @@\n 8\n 9\n 10\n-11\n 12\n",
+		},
+		{
+			name:      "missing newline",
+			current:   "a\nb",
+			candidate: "a\nb\n",
+			want:      "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name:      "joined hunk",
+			current:   "1\n2\n3\n4\n5\n6\n7\n8\n",
+			candidate: "1\n3\n4\n5\n6\n7\n8\nneun\n",
+			want:      "--- a\n+++ b\n@@ -1,8 +1,8 @@\n 1\n-2\n 3\n 4\n 5\n 6\n 7\n 8\n+neun\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, unifiedDiff("a", "b", tt.current, tt.candidate))
+		})
+	}
+}
+
+func TestNormalizeOutput(t *testing.T) {
+	is := require.New(t)
+	is.Equal(NormalizeOutput("json", []byte(`{"a": [1, 2]}`)), NormalizeOutput("json", []byte("{\n  \"a\": [1,2]\n}")))
+	is.Equal(NormalizeOutput("json5", []byte(`{a: [1, 2,],}`)), NormalizeOutput("json", []byte(`{"a": [1, 2]}`)))
+	is.Equal("not parseable ({)", NormalizeOutput("json", []byte("{")))
+	is.Equal("a b", NormalizeOutput("txt", []byte("a b")))
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/pkg/errors"
+)
+
+var pathSegmentPattern = regexp.MustCompile(`\.([^.\[]+)|\[\]`)
+
+// TemplateVariables are the variables read by the templates of a template.
+type TemplateVariables struct {
+	// Paths of the variables read by the templates, e.g. ".interfaces[].ipv4" for the ipv4 attribute of each interface.
+	// Paths which lead to other paths are left out.
+	Paths []string `json:"paths"`
+	// Skeleton is a variable set with all variables, e.g. to start a variables file.
+	Skeleton map[string]interface{} `json:"skeleton"`
+}
+
+// TemplateVariables discovers the variables read by the templates of the template folder from the parse trees.
+// The templates are followed from the main templates of the outputs, template calls are followed with the data
+// passed to them. Variables accessed with dynamic keys, e.g. index .ports $name, are reported up to the dynamic key.
+func (r *Repository) TemplateVariables(templateFolder string) (*TemplateVariables, error) {
+	cached, err := r.getTemplate(templateFolder)
+	if err != nil {
+		return nil, err
+	}
+	parsed, ok := cached.templates.(*goTemplate)
+	if !ok {
+		return nil, errors.WithMessage(ErrNotSupported, "variable discovery")
+	}
+	d := &discovery{
+		templates: parsed.templates,
+		config:    cached.config,
+		paths:     make(map[string]bool),
+		visited:   make(map[string]bool),
+	}
+	for _, output := range cached.outputs {
+		d.walkTemplate(output.mainTemplate, rootPath)
+	}
+	paths := d.leafPaths()
+	return &TemplateVariables{Paths: paths, Skeleton: variablesSkeleton(paths)}, nil
+}
+
+// variablePath is the path of a value in the variables, paths of computed values are unknown.
+type variablePath struct {
+	path  string
+	known bool
+}
+
+var (
+	rootPath    = variablePath{known: true}
+	unknownPath = variablePath{}
+)
+
+// field returns the path of the fields below the path.
+func (p variablePath) field(names ...string) variablePath {
+	if !p.known || len(names) == 0 {
+		return p
+	}
+	return variablePath{path: p.path + "." + strings.Join(names, "."), known: true}
+}
+
+// element returns the path of the elements of a list or map.
+func (p variablePath) element() variablePath {
+	if !p.known {
+		return p
+	}
+	return variablePath{path: p.path + "[]", known: true}
+}
+
+// discovery walks the parse trees and records the variable paths.
+type discovery struct {
+	templates *template.Template
+	config    *TemplateConfig
+	paths     map[string]bool
+	// visited are the templates already walked with a dot path.
+	visited map[string]bool
+	// calls are the templates currently walked, recursive templates are walked once.
+	calls []string
+}
+
+func (d *discovery) record(p variablePath) {
+	if p.known && len(p.path) > 0 {
+		d.paths[p.path] = true
+	}
+}
+
+// walkTemplate walks the named template with the dot path, $ is the dot path in the template.
+func (d *discovery) walkTemplate(name string, dot variablePath) {
+	t := d.templates.Lookup(name)
+	if t == nil || t.Tree == nil || t.Tree.Root == nil || !dot.known {
+		return
+	}
+	key := name + "\x00" + dot.path
+	if d.visited[key] || containsString(d.calls, name) {
+		return
+	}
+	d.visited[key] = true
+	d.calls = append(d.calls, name)
+	d.walkList(t.Tree.Root, dot, map[string]variablePath{"$": dot})
+	d.calls = d.calls[:len(d.calls)-1]
+}
+
+// walkList walks the nodes of a list. Variables declared in the list are added to the vars.
+func (d *discovery) walkList(list *parse.ListNode, dot variablePath, vars map[string]variablePath) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			result := d.pipe(n.Pipe, dot, vars)
+			bindDecls(n.Pipe, vars, result)
+		case *parse.IfNode:
+			inner := copyVars(vars)
+			bindDecls(n.Pipe, inner, d.pipe(n.Pipe, dot, inner))
+			d.walkList(n.List, dot, inner)
+			d.walkList(n.ElseList, dot, copyVars(vars))
+		case *parse.RangeNode:
+			inner := copyVars(vars)
+			element := d.pipe(n.Pipe, dot, inner).element()
+			if len(n.Pipe.Decl) == 2 {
+				bindDecls(n.Pipe, inner, unknownPath, element)
+			} else {
+				bindDecls(n.Pipe, inner, element)
+			}
+			d.walkList(n.List, element, inner)
+			d.walkList(n.ElseList, dot, copyVars(vars))
+		case *parse.WithNode:
+			inner := copyVars(vars)
+			result := d.pipe(n.Pipe, dot, inner)
+			bindDecls(n.Pipe, inner, result)
+			d.walkList(n.List, result, inner)
+			d.walkList(n.ElseList, dot, copyVars(vars))
+		case *parse.TemplateNode:
+			data := unknownPath
+			if n.Pipe != nil {
+				data = d.pipe(n.Pipe, dot, vars)
+			}
+			d.walkTemplate(n.Name, data)
+		}
+	}
+}
+
+// pipe walks the commands of a pipeline and returns the path of its result.
+// Only the result of a pipeline with a single command can be a variable path.
+func (d *discovery) pipe(pipe *parse.PipeNode, dot variablePath, vars map[string]variablePath) variablePath {
+	if pipe == nil {
+		return unknownPath
+	}
+	result := unknownPath
+	for i, command := range pipe.Cmds {
+		path := d.command(command, dot, vars)
+		if i == 0 && len(pipe.Cmds) == 1 {
+			result = path
+		}
+	}
+	return result
+}
+
+// command records the variables of the command arguments and returns the path of its result.
+func (d *discovery) command(command *parse.CommandNode, dot variablePath, vars map[string]variablePath) variablePath {
+	if len(command.Args) == 1 {
+		path := d.arg(command.Args[0], dot, vars)
+		d.record(path)
+		return path
+	}
+	if function, ok := command.Args[0].(*parse.IdentifierNode); ok {
+		switch function.Ident {
+		case "index":
+			// index with literal keys reads a path, e.g. index .ports "uplink" 0 reads .ports.uplink[]
+			path := d.arg(command.Args[1], dot, vars)
+			for _, key := range command.Args[2:] {
+				switch k := key.(type) {
+				case *parse.StringNode:
+					path = path.field(k.Text)
+				case *parse.NumberNode:
+					path = path.element()
+				default:
+					d.record(d.arg(key, dot, vars))
+					d.record(path)
+					return unknownPath
+				}
+			}
+			d.record(path)
+			return path
+		case "feature":
+			for _, variable := range []string{d.config.VersionVariable, d.config.PlatformVariable} {
+				if len(variable) > 0 {
+					d.record(rootPath.field(variable))
+				}
+			}
+		}
+	}
+	for _, arg := range command.Args {
+		d.record(d.arg(arg, dot, vars))
+	}
+	return unknownPath
+}
+
+// arg returns the path of a command argument.
+func (d *discovery) arg(node parse.Node, dot variablePath, vars map[string]variablePath) variablePath {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return dot.field(n.Ident...)
+	case *parse.VariableNode:
+		variable, ok := vars[n.Ident[0]]
+		if !ok {
+			return unknownPath
+		}
+		return variable.field(n.Ident[1:]...)
+	case *parse.DotNode:
+		return dot
+	case *parse.ChainNode:
+		if pipe, ok := n.Node.(*parse.PipeNode); ok {
+			return d.pipe(pipe, dot, vars).field(n.Field...)
+		}
+	case *parse.PipeNode:
+		return d.pipe(n, dot, vars)
+	}
+	return unknownPath
+}
+
+// bindDecls binds the variables declared by the pipeline to the paths.
+func bindDecls(pipe *parse.PipeNode, vars map[string]variablePath, paths ...variablePath) {
+	if pipe == nil {
+		return
+	}
+	for i, decl := range pipe.Decl {
+		if i < len(paths) {
+			vars[decl.Ident[0]] = paths[i]
+		} else {
+			vars[decl.Ident[0]] = unknownPath
+		}
+	}
+}
+
+func copyVars(vars map[string]variablePath) map[string]variablePath {
+	copied := make(map[string]variablePath, len(vars))
+	for name, path := range vars {
+		copied[name] = path
+	}
+	return copied
+}
+
+// leafPaths returns the sorted paths without the paths which lead to other paths.
+func (d *discovery) leafPaths() []string {
+	paths := make([]string, 0, len(d.paths))
+	for path := range d.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	leaves := make([]string, 0, len(paths))
+	for i, path := range paths {
+		// Sorted paths are directly followed by the paths they lead to.
+		if i+1 < len(paths) && (strings.HasPrefix(paths[i+1], path+".") || strings.HasPrefix(paths[i+1], path+"[]")) {
+			continue
+		}
+		leaves = append(leaves, path)
+	}
+	return leaves
+}
+
+// variablesSkeleton returns a variable set with all paths, lists have a single element and the values are nil.
+func variablesSkeleton(paths []string) map[string]interface{} {
+	var skeleton interface{} = make(map[string]interface{})
+	for _, path := range paths {
+		var segments []string
+		for _, match := range pathSegmentPattern.FindAllStringSubmatch(path, -1) {
+			if match[0] == "[]" {
+				segments = append(segments, "[]")
+			} else {
+				segments = append(segments, match[1])
+			}
+		}
+		if len(segments) > 0 && segments[0] != "[]" {
+			skeleton = addSkeletonPath(skeleton, segments)
+		}
+	}
+	return skeleton.(map[string]interface{})
+}
+
+func addSkeletonPath(value interface{}, segments []string) interface{} {
+	if len(segments) == 0 {
+		return value
+	}
+	if segments[0] == "[]" {
+		list, ok := value.([]interface{})
+		if !ok || len(list) == 0 {
+			list = []interface{}{nil}
+		}
+		list[0] = addSkeletonPath(list[0], segments[1:])
+		return list
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+	}
+	m[segments[0]] = addSkeletonPath(m[segments[0]], segments[1:])
+	return m
+}
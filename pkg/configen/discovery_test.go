@@ -0,0 +1,96 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_TemplateVariables(t *testing.T) {
+	tests := []struct {
+		templateFolder string
+		want           *TemplateVariables
+	}{
+		{
+			templateFolder: "d1",
+			want: &TemplateVariables{
+				Paths: []string{
+					".description",
+					".device.name",
+					".device.site.code",
+					".image.version",
+					".interfaces[].name",
+					".interfaces[].vlans[].id",
+					".ntp.server",
+					".ports.uplink[]",
+					".root.child",
+					".root.label",
+				},
+				Skeleton: map[string]interface{}{
+					"description": nil,
+					"device":      map[string]interface{}{"name": nil, "site": map[string]interface{}{"code": nil}},
+					"image":       map[string]interface{}{"version": nil},
+					"interfaces":  []interface{}{map[string]interface{}{"name": nil, "vlans": []interface{}{map[string]interface{}{"id": nil}}}},
+					"ntp":         map[string]interface{}{"server": nil},
+					"ports":       map[string]interface{}{"uplink": []interface{}{nil}},
+					"root":        map[string]interface{}{"child": nil, "label": nil},
+				},
+			},
+		},
+		{
+			templateFolder: "g11",
+			want: &TemplateVariables{
+				Paths: []string{".name", ".ntp.prefer", ".ntp.servers[]", ".site"},
+				Skeleton: map[string]interface{}{
+					"name": nil,
+					"ntp":  map[string]interface{}{"prefer": nil, "servers": []interface{}{nil}},
+					"site": nil,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.templateFolder, func(t *testing.T) {
+			r := NewRepository("testdata/templates")
+			got, err := r.TemplateVariables(tt.templateFolder)
+			require.NoError(t, err)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("TemplateVariables() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRepository_TemplateVariablesErrors(t *testing.T) {
+	r := NewRepository("testdata/templates")
+	_, err := r.TemplateVariables("missing")
+	require.True(t, errors.Is(err, ErrTemplateConfigNotFound))
+}
+
+func TestVariablesSkeleton(t *testing.T) {
+	got := variablesSkeleton([]string{".a[].b", ".a[].c[]", ".d", "[].e"})
+	want := map[string]interface{}{
+		"a": []interface{}{map[string]interface{}{"b": nil, "c": []interface{}{nil}}},
+		"d": nil,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("variablesSkeleton() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// EngineFactory creates a template engine.
+// The options are the engine specific options of the engine_options key in the config.yaml.
+type EngineFactory func(options map[string]interface{}) (TemplateEngine, error)
+
+var (
+	enginesMutex sync.RWMutex
+	engines      = map[Engine]EngineFactory{
+		EngineGolang: newGoEngineFromOptions,
+	}
+)
+
+// RegisterEngine makes a template engine available under the given name.
+// Templates select the engine by the engine key in the config.yaml.
+// It panics if the name is empty, the factory is nil or an engine with the same name is already registered.
+func RegisterEngine(name Engine, factory EngineFactory) {
+	enginesMutex.Lock()
+	defer enginesMutex.Unlock()
+	if name == "" {
+		panic("configen: RegisterEngine name is empty")
+	}
+	if factory == nil {
+		panic("configen: RegisterEngine factory is nil")
+	}
+	if _, ok := engines[name]; ok {
+		panic(fmt.Sprintf("configen: RegisterEngine called twice for engine %s", name))
+	}
+	engines[name] = factory
+}
+
+// Engines returns the names of all registered template engines.
+func Engines() []Engine {
+	enginesMutex.RLock()
+	defer enginesMutex.RUnlock()
+	names := make([]Engine, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// newEngine creates the template engine which is selected in the template config.
+// If no engine is selected the golang engine is used.
+func newEngine(config *TemplateConfig) (TemplateEngine, error) {
+	name := config.TemplateEngine
+	if name == "" {
+		name = EngineGolang
+	}
+	enginesMutex.RLock()
+	factory, ok := engines[name]
+	enginesMutex.RUnlock()
+	if !ok {
+		return nil, errors.WithMessage(ErrEngineNotFound, string(name))
+	}
+	engine, err := factory(config.EngineOptions)
+	if err != nil {
+		return nil, errors.WithMessage(err, string(name))
+	}
+	return engine, nil
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// echoEngine renders the text engine option for each template.
+type echoEngine struct {
+	text string
+}
+
+func (e *echoEngine) Parse(_ *TemplateConfig) (ParsedTemplate, error) {
+	return e, nil
+}
+
+func (e *echoEngine) Execute(_ context.Context, w io.Writer, _ string, _ map[string]interface{}) error {
+	_, err := w.Write([]byte(e.text))
+	return err
+}
+
+// blockingEngine blocks each execution until the context is done.
+type blockingEngine struct{}
+
+// blockingStarted receives a value when an execution of the blocking engine starts.
+var blockingStarted = make(chan struct{}, 1)
+
+func (e *blockingEngine) Parse(_ *TemplateConfig) (ParsedTemplate, error) {
+	return e, nil
+}
+
+func (e *blockingEngine) Execute(ctx context.Context, _ io.Writer, _ string, _ map[string]interface{}) error {
+	blockingStarted <- struct{}{}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// filesEngine renders the content of the named template file, it only uses the exported template config.
+type filesEngine struct {
+	files map[string][]byte
+}
+
+func (e *filesEngine) Parse(config *TemplateConfig) (ParsedTemplate, error) {
+	files := make(map[string][]byte)
+	for _, pattern := range config.Patterns() {
+		matches, err := fs.Glob(config.Store(), pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range matches {
+			if files[path.Base(file)], err = fs.ReadFile(config.Store(), file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &filesEngine{files: files}, nil
+}
+
+func (e *filesEngine) Execute(_ context.Context, w io.Writer, templateName string, _ map[string]interface{}) error {
+	content, ok := e.files[templateName]
+	if !ok {
+		return fmt.Errorf("template %s not found", templateName)
+	}
+	_, err := w.Write(content)
+	return err
+}
+
+func init() {
+	RegisterEngine("echo", func(options map[string]interface{}) (TemplateEngine, error) {
+		return &echoEngine{text: fmt.Sprint(options["text"])}, nil
+	})
+	RegisterEngine("files", func(options map[string]interface{}) (TemplateEngine, error) {
+		return &filesEngine{}, nil
+	})
+	RegisterEngine("blocking", func(options map[string]interface{}) (TemplateEngine, error) {
+		return &blockingEngine{}, nil
+	})
+}
+
+func TestRegisterEngine(t *testing.T) {
+	is := require.New(t)
+	is.Contains(Engines(), Engine(EngineGolang))
+	is.Contains(Engines(), Engine("echo"))
+	is.Panics(func() {
+		RegisterEngine("echo", func(options map[string]interface{}) (TemplateEngine, error) { return nil, nil })
+	})
+
+	r := NewRepository("testdata/templates")
+	got, _, err := r.GenerateFile(context.Background(), "g6", nil)
+	is.NoError(err)
+	is.Equal("Hello from the echo engine", string(got))
+}
+
+func TestRepository_GenerateFileReadsStore(t *testing.T) {
+	is := require.New(t)
+	r := NewStoreRepository(NewMemoryStore(map[string][]byte{
+		"base/config.yaml":  []byte("engine: files\nmain_template: main.txt\nmain_pattern: \"*.txt\"\n"),
+		"base/main.txt":     []byte("base"),
+		"base/footer.txt":   []byte("footer"),
+		"child/config.yaml": []byte("extends: base\nmain_template: footer.txt\nmain_pattern: \"*.txt\"\n"),
+		"child/main.txt":    []byte("child"),
+	}))
+	got, _, err := r.GenerateFile(context.Background(), "base", nil)
+	is.NoError(err)
+	is.Equal("base", string(got))
+	// The inherited patterns are relative to the store as well.
+	got, _, err = r.GenerateFile(context.Background(), "child", nil)
+	is.NoError(err)
+	is.Equal("footer", string(got))
+}
+
+func TestRepository_GenerateFile_DoesNotBlockChanges(t *testing.T) {
+	is := require.New(t)
+	config := []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n")
+	r := NewStoreRepository(NewMemoryStore(map[string][]byte{
+		"slow/config.yaml": []byte("engine: blocking\nmain_template: main\n"),
+		"fast/config.yaml": config,
+		"fast/main.gotext": []byte("v1"),
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := r.GenerateFile(ctx, "slow", nil)
+		done <- err
+	}()
+	<-blockingStarted
+
+	changed := make(chan error, 1)
+	go func() {
+		_, err := r.PutTemplate("fast", map[string][]byte{"config.yaml": config, "main.gotext": []byte("v2")})
+		changed <- err
+	}()
+	select {
+	case err := <-changed:
+		is.NoError(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("PutTemplate is blocked by a running generation")
+	}
+	got, _, err := r.GenerateFile(context.Background(), "fast", nil)
+	is.NoError(err)
+	is.Equal("v2", string(got))
+
+	cancel()
+	is.True(errors.Is(<-done, ErrRenderCanceled))
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	sv2 "github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// featuresFile is the name of the optional file with the named features, either in the template path or in a template folder.
+const featuresFile = "features.yaml"
+
+// defaultPlatform is the platform of the constraint which applies to all platforms without their own constraint.
+const defaultPlatform = "default"
+
+// FeatureConstraints maps the platforms to the version constraint of a feature.
+type FeatureConstraints map[string]string
+
+// UnmarshalYAML reads either a map of platforms to constraints or a single constraint for all platforms.
+func (c *FeatureConstraints) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*c = FeatureConstraints{defaultPlatform: value.Value}
+		return nil
+	}
+	constraints := make(map[string]string)
+	if err := value.Decode(&constraints); err != nil {
+		return err
+	}
+	*c = constraints
+	return nil
+}
+
+// Features maps the feature names to their constraints.
+type Features map[string]FeatureConstraints
+
+// FeatureStatus tells whether a feature is enabled for a version and platform.
+type FeatureStatus struct {
+	// Name of the feature (e.g. "bgp-evpn")
+	Name string `json:"name"`
+	// Constraint is the version constraint of the feature for the platform, empty if the feature isn't available on the platform.
+	Constraint string `json:"constraint,omitempty"`
+	// Enabled is set if the version matches the constraint.
+	Enabled bool `json:"enabled"`
+}
+
+// constraint returns the constraint of the feature for the platform.
+func (f Features) constraint(name string, platform string) (string, bool) {
+	constraints, ok := f[name]
+	if !ok {
+		return "", false
+	}
+	if constraint, ok := constraints[platform]; ok && len(platform) > 0 {
+		return constraint, true
+	}
+	constraint, ok := constraints[defaultPlatform]
+	return constraint, ok
+}
+
+// enabled checks whether the feature is enabled for the version and platform.
+// Features without constraint for the platform are disabled.
+func (f Features) enabled(name string, version *sv2.Version, platform string) (bool, error) {
+	if _, ok := f[name]; !ok {
+		return false, errors.WithMessage(ErrFeatureNotFound, name)
+	}
+	constraint, ok := f.constraint(name, platform)
+	if !ok || len(constraint) == 0 {
+		return false, nil
+	}
+	c, err := sv2.NewConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(version), nil
+}
+
+// Features returns the status of all features of the template for the version and platform, sorted by name.
+func (r *Repository) Features(templateFolder string, version string, platform string) ([]FeatureStatus, error) {
+	template, err := r.getTemplate(templateFolder)
+	if err != nil {
+		return nil, err
+	}
+	v, err := sv2.NewVersion(version)
+	if err != nil {
+		return nil, errors.WithMessagef(ErrInvalidFeatureVersion, "%s: %v", version, err)
+	}
+	features := template.config.features
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]FeatureStatus, 0, len(names))
+	for _, name := range names {
+		enabled, err := features.enabled(name, v, platform)
+		if err != nil {
+			return nil, err
+		}
+		constraint, _ := features.constraint(name, platform)
+		result = append(result, FeatureStatus{Name: name, Constraint: constraint, Enabled: enabled})
+	}
+	return result, nil
+}
+
+// featureFunc returns the feature function of a generation.
+// It checks the features against the version and platform variables named in the template config.
+func (c *TemplateConfig) featureFunc(variables map[string]interface{}) func(name string) (bool, error) {
+	return func(name string) (bool, error) {
+		if len(c.VersionVariable) == 0 {
+			return false, fmt.Errorf("feature %s: version_variable is not set in the template config", name)
+		}
+		value, ok := lookupVariable(variables, c.VersionVariable)
+		if !ok || value == nil {
+			return false, fmt.Errorf("version variable %s is not set", c.VersionVariable)
+		}
+		version, err := sv2.NewVersion(fmt.Sprint(value))
+		if err != nil {
+			return false, fmt.Errorf("version variable %s: %v", c.VersionVariable, err)
+		}
+		platform := ""
+		if len(c.PlatformVariable) > 0 {
+			if value, ok := lookupVariable(variables, c.PlatformVariable); ok && value != nil {
+				platform = fmt.Sprint(value)
+			}
+		}
+		return c.features.enabled(name, version, platform)
+	}
+}
+
+// unknownFeature is the feature function of templates without features.
+func unknownFeature(name string) (bool, error) {
+	return false, errors.WithMessage(ErrFeatureNotFound, name)
+}
+
+// lookupVariable returns the variable of a dot separated path (e.g. "image.version").
+func lookupVariable(variables map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = variables
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// readFeaturesFile reads a features.yaml, a missing file results in no features.
+func readFeaturesFile(store TemplateStore, file string) (Features, error) {
+	content, err := fs.ReadFile(store, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	features := make(Features)
+	if err := yaml.Unmarshal(content, &features); err != nil {
+		return nil, errors.WithMessagef(ErrInvalidFeatures, "%s: %v", file, err)
+	}
+	for name, constraints := range features {
+		for platform, constraint := range constraints {
+			if len(constraint) == 0 {
+				continue
+			}
+			if _, err := sv2.NewConstraint(constraint); err != nil {
+				return nil, errors.WithMessagef(ErrInvalidFeatures, "%s: %s on %s: %v", file, name, platform, err)
+			}
+		}
+	}
+	return features, nil
+}
+
+// mergeFeatures merges the features over the base features.
+// The constraints of the features replace the constraints of the same platforms of the base features.
+func mergeFeatures(base Features, features Features) Features {
+	if len(base) == 0 {
+		return features
+	}
+	merged := make(Features, len(base)+len(features))
+	for name, constraints := range base {
+		merged[name] = constraints
+	}
+	for name, constraints := range features {
+		mergedConstraints := make(FeatureConstraints, len(merged[name])+len(constraints))
+		for platform, constraint := range merged[name] {
+			mergedConstraints[platform] = constraint
+		}
+		for platform, constraint := range constraints {
+			mergedConstraints[platform] = constraint
+		}
+		merged[name] = mergedConstraints
+	}
+	return merged
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_Features(t *testing.T) {
+	is := require.New(t)
+	r := NewRepository("testdata/templates")
+
+	features, err := r.Features("g21", "21.4.0", "q2c")
+	is.NoError(err)
+	is.Equal([]FeatureStatus{
+		{Name: "bgp-evpn", Constraint: ">= 21.5.0", Enabled: false},
+		{Name: "local-pref", Constraint: ">= 21.0.0", Enabled: true},
+		{Name: "srv6", Constraint: "", Enabled: false},
+	}, features)
+
+	features, err = r.Features("g21", "22.1.0", "")
+	is.NoError(err)
+	is.Equal([]FeatureStatus{
+		{Name: "bgp-evpn", Constraint: ">= 21.3.0", Enabled: true},
+		{Name: "local-pref", Constraint: ">= 21.0.0", Enabled: true},
+		{Name: "srv6", Constraint: ">= 22.1.0", Enabled: true},
+	}, features)
+
+	_, err = r.Features("g21", "latest", "")
+	is.True(errors.Is(err, ErrInvalidFeatureVersion))
+}
+
+func TestRepository_FeaturesInheritance(t *testing.T) {
+	is := require.New(t)
+	templatePath, err := ioutil.TempDir("", "templates")
+	is.NoError(err)
+	defer func() { _ = os.RemoveAll(templatePath) }()
+	write := func(file string, content string) {
+		is.NoError(os.MkdirAll(filepath.Dir(filepath.Join(templatePath, file)), 0755))
+		is.NoError(ioutil.WriteFile(filepath.Join(templatePath, file), []byte(content), 0644))
+	}
+	write("features.yaml", "bgp-evpn:\n  default: \">= 21.3.0\"\n  q2c: \">= 21.5.0\"\nsrv6: \">= 22.1.0\"\n")
+	write("base/config.yaml", "main_pattern: \"*.gotext\"\nmain_template: main.gotext\nversion_variable: version\nplatform_variable: platform\n")
+	write("base/features.yaml", "srv6: \">= 21.1.0\"\n")
+	write("base/main.gotext", `{{feature "bgp-evpn"}} {{feature "srv6"}}`)
+	write("child/config.yaml", "extends: base\n")
+	write("child/features.yaml", "bgp-evpn:\n  q2c: \">= 21.4.0\"\n")
+
+	r := NewRepository(templatePath)
+	variables := map[string]interface{}{"version": "21.4.0", "platform": "q2c"}
+	got, _, err := r.GenerateFile(context.Background(), "base", variables)
+	is.NoError(err)
+	is.Equal("false true", string(got))
+	got, _, err = r.GenerateFile(context.Background(), "child", variables)
+	is.NoError(err)
+	is.Equal("true true", string(got))
+
+	// The features are checked against the version variable of each generation.
+	got, _, err = r.GenerateFile(context.Background(), "child", map[string]interface{}{"version": "21.0.0"})
+	is.NoError(err)
+	is.Equal("false false", string(got))
+	_, _, err = r.GenerateFile(context.Background(), "child", map[string]interface{}{})
+	is.Error(err)
+
+	write("base/main.gotext", `{{feature "unknown"}}`)
+	_, _, err = NewRepository(templatePath).GenerateFile(context.Background(), "child", variables)
+	is.Error(err)
+	is.Contains(err.Error(), ErrFeatureNotFound.Error())
+}
@@ -18,9 +18,18 @@ package configen
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
 	"text/template"
+	"text/template/parse"
 
 	"github.com/Masterminds/sprig"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
@@ -31,38 +40,259 @@ type GoEngine struct {
 // Ensure, that GoEngine does implement TemplateEngine.
 var _ TemplateEngine = &GoEngine{}
 
-//newGoEngine creates a new code generation repository
+// newGoEngine creates a new code generation repository
 func newGoEngine() (*GoEngine, error) {
 	return &GoEngine{}, nil
 }
 
-// GenerateFile executes a template and adds a variable set
-func (r GoEngine) GenerateFile(config *TemplateConfig, data map[string]interface{}) ([]byte, string, error) {
+// newGoEngineFromOptions creates the golang engine, the golang engine doesn't support any engine options.
+func newGoEngineFromOptions(options map[string]interface{}) (TemplateEngine, error) {
+	for option := range options {
+		return nil, errors.Errorf("unknown engine option %s", option)
+	}
+	return newGoEngine()
+}
+
+// Parse parses the inherited, main and include templates of the template config from the template store.
+// Later parsed templates replace earlier templates of the same name.
+func (r GoEngine) Parse(config *TemplateConfig) (ParsedTemplate, error) {
+	patterns := config.Patterns()
+	if len(patterns) == 0 {
+		return nil, errors.New("main_pattern is not set")
+	}
+	if config.store == nil {
+		return nil, errors.New("template store is not set")
+	}
+	funcs := goFuncMap()
+	funcs["lookup"] = dataLookup(config.data)
+	templates := template.New("base").Funcs(funcs)
+	if len(config.MissingKey) > 0 {
+		templates = templates.Option("missingkey=" + string(config.MissingKey))
+	}
+	if config.Delimiters != nil {
+		templates = templates.Delims(config.Delimiters.Left, config.Delimiters.Right)
+	}
+	// files maps the template file names to their paths, later patterns replace files of earlier patterns.
+	files := make(map[string]string)
+	for _, pattern := range patterns {
+		if pattern != config.MainPattern {
+			if err := checkDelimiters(config.store, pattern, config.delimiters()); err != nil {
+				return nil, err
+			}
+		}
+		matches, err := fs.Glob(config.store, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range matches {
+			files[path.Base(file)] = file
+		}
+		if templates, err = templates.ParseFS(config.store, pattern); err != nil {
+			if renderError := newRenderError(err, config.store, files); renderError != nil {
+				return nil, renderError
+			}
+			return nil, err
+		}
+	}
+	if err := checkFunctions(templates, config, funcs); err != nil {
+		return nil, err
+	}
+	if err := addRenderSteps(templates, config.delimiters()); err != nil {
+		return nil, err
+	}
+	if config.MissingKey == MissingKeyModeZero {
+		if err := addZeroValues(templates, config.delimiters()); err != nil {
+			return nil, err
+		}
+	}
+	t := &goTemplate{templates: templates, store: config.store, files: files}
+	if len(config.features) > 0 {
+		t.featureFunc = config.featureFunc
+	}
+	return t, nil
+}
+
+// checkDelimiters reports shared include files which are written for other delimiters.
+// Such files would be parsed as plain text instead of failing.
+func checkDelimiters(store TemplateStore, pattern string, delimiters Delimiters) error {
+	if delimiters == defaultDelimiters {
+		return nil
+	}
+	files, err := fs.Glob(store, pattern)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		content, err := fs.ReadFile(store, file)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(content, []byte(delimiters.Left)) && bytes.Contains(content, []byte(defaultDelimiters.Left)) {
+			return errors.WithMessagef(ErrInvalidDelimiters, "include file %s uses %s %s instead of %s %s", file,
+				defaultDelimiters.Left, defaultDelimiters.Right, delimiters.Left, delimiters.Right)
+		}
+	}
+	return nil
+}
+
+// goFuncMap returns the functions available in the go templates.
+func goFuncMap() template.FuncMap {
 	// Augment sprig with an addition versionMatches function.
 	f := sprig.TxtFuncMap()
 	f["featureIsEnabled"] = featureIsEnabled
-	t := template.New("base").Funcs(f)
-	templates, err := t.ParseGlob(config.MainPattern)
+	f["until"] = until
+	f["untilStep"] = untilStep
+	f["repeat"] = repeat
+	// feature is bound to the variables of each execution, templates without features know no feature.
+	f["feature"] = unknownFeature
+	for _, functions := range []map[string]interface{}{netFuncMap(), ifnameFuncMap()} {
+		for name, function := range functions {
+			f[name] = function
+		}
+	}
+	return f
+}
+
+// goTemplate is a parsed go template set
+type goTemplate struct {
+	templates *template.Template
+	// store is the template store the templates were parsed from.
+	store TemplateStore
+	// files maps the template file names to their paths in the template store.
+	files map[string]string
+	// featureFunc returns the feature function of an execution, nil if the template has no features.
+	featureFunc func(variables map[string]interface{}) func(name string) (bool, error)
+}
+
+// Execute executes the named template with the variable set.
+// The go templates can't be interrupted from the outside, the execution stops at the next loop iteration,
+// template call or output after the context is done.
+func (t *goTemplate) Execute(ctx context.Context, w io.Writer, templateName string, data map[string]interface{}) error {
+	log.Debug().Str("template_name", templateName).Msg("Execute")
+	// The functions bound to this execution are added to a clone, which shares the parse trees.
+	templates, err := t.templates.Clone()
 	if err != nil {
-		return nil, "", err
+		return err
 	}
-	if len(config.IncludePattern) > 0 {
-		templates, err = templates.ParseGlob(config.IncludePattern)
+	funcs := template.FuncMap{renderStepFunc: (&renderSteps{ctx: ctx, limit: maxRenderSteps}).step}
+	if t.featureFunc != nil {
+		funcs["feature"] = t.featureFunc(data)
 	}
-	if err != nil {
-		return nil, "", err
+	templates = templates.Funcs(funcs)
+	done := make(chan error, 1)
+	go func() {
+		done <- templates.ExecuteTemplate(w, templateName, data)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Error().Err(err).Msg("")
+			if missingKeyError := newMissingKeyError(err, t.store, t.files); missingKeyError != nil {
+				return missingKeyError
+			}
+			if renderError := newRenderError(err, t.store, t.files); renderError != nil {
+				return renderError
+			}
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	result, err := r.executeTemplate(config.MainTemplate, templates, data)
-	return result, config.OutputFormat, err
 }
 
-func (r *GoEngine) executeTemplate(templateName string, template *template.Template, data interface{}) ([]byte, error) {
-	log.Debug().Str("template_name", templateName).Msg("Execute")
-	var tpl bytes.Buffer
-	err := template.ExecuteTemplate(&tpl, templateName, data)
+// addZeroValues passes the output of all actions through the zero value function.
+// The go templates render "<no value>" for missing keys of maps of interface values even with missingkey=zero,
+// because the zero value of an interface is no value.
+func addZeroValues(templates *template.Template, delimiters Delimiters) error {
+	// The command is parsed with the delimiters of the templates, so that the parse trees can still be printed.
+	action := fmt.Sprintf("%s%s%s", delimiters.Left, zeroValueFunc, delimiters.Right)
+	trees, err := parse.Parse(zeroValueFunc, action, delimiters.Left, delimiters.Right, map[string]interface{}{zeroValueFunc: zeroValue})
 	if err != nil {
-		log.Error().Err(err).Msg("")
-		return nil, err
+		return err
+	}
+	command := trees[zeroValueFunc].Root.Nodes[0].(*parse.ActionNode).Pipe.Cmds[0]
+	for _, t := range templates.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		walkNodes(t.Tree.Root, func(node parse.Node) bool {
+			// Actions with declarations don't render anything.
+			if action, ok := node.(*parse.ActionNode); ok && len(action.Pipe.Decl) == 0 {
+				cmds := action.Pipe.Cmds
+				if len(cmds) == 0 || cmds[len(cmds)-1].String() != command.String() {
+					action.Pipe.Cmds = append(cmds, command)
+				}
+			}
+			return true
+		})
+	}
+	templates.Funcs(template.FuncMap{zeroValueFunc: zeroValue})
+	return nil
+}
+
+// zeroValueFunc is the function that renders missing values as empty string.
+const zeroValueFunc = "zeroValue"
+
+// zeroValue replaces no value by an empty string and returns all other values unchanged.
+func zeroValue(value interface{}) interface{} {
+	if value == nil {
+		return ""
+	}
+	return value
+}
+
+var (
+	missingKeyPattern        = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+	execErrorLocationPattern = regexp.MustCompile(`^template: ([^:]+):(\d+):(\d+): `)
+	execErrorFieldPattern    = regexp.MustCompile(` at <([^>]*)>: `)
+)
+
+// MissingKeyError is returned if a template accesses a variable which is not set and missing_key is error.
+type MissingKeyError struct {
+	// Key is the name of the missing variable
+	Key string
+	// Expression is the template expression accessing the variable (e.g. ".device.site")
+	Expression string
+	// Template is the template file accessing the variable
+	Template string
+	// Line of the access, starting with 1. 0 if the line is unknown.
+	Line int
+	// Column of the access, starting with 1. 0 if the column is unknown.
+	Column int
+	// Snippet shows the lines of the template file around the access
+	Snippet string
+	// Err is the error of the template engine
+	Err error
+}
+
+func (e *MissingKeyError) Error() string {
+	return fmt.Sprintf("%v %q of <%s> in template %s at line %d, column %d", ErrMissingKey, e.Key, e.Expression, e.Template, e.Line, e.Column)
+}
+
+// Unwrap allows to check the error with errors.Is(err, ErrMissingKey).
+func (e *MissingKeyError) Unwrap() error {
+	return ErrMissingKey
+}
+
+// newMissingKeyError converts the execution error of a missing variable, it returns nil for all other errors.
+func newMissingKeyError(err error, store TemplateStore, files map[string]string) *MissingKeyError {
+	var execError template.ExecError
+	if !errors.As(err, &execError) {
+		return nil
+	}
+	key := missingKeyPattern.FindStringSubmatch(err.Error())
+	if key == nil {
+		return nil
+	}
+	missingKeyError := &MissingKeyError{Key: key[1], Template: execError.Name, Err: err}
+	if field := execErrorFieldPattern.FindStringSubmatch(err.Error()); field != nil {
+		missingKeyError.Expression = field[1]
+	}
+	if location := execErrorLocationPattern.FindStringSubmatch(err.Error()); location != nil {
+		missingKeyError.Template = location[1]
+		missingKeyError.Line, _ = strconv.Atoi(location[2])
+		missingKeyError.Column, _ = strconv.Atoi(location[3])
 	}
-	return tpl.Bytes(), nil
+	missingKeyError.Snippet = fileSnippet(store, files[missingKeyError.Template], missingKeyError.Line, missingKeyError.Column)
+	return missingKeyError
 }
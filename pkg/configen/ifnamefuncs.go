@@ -0,0 +1,235 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxRangeExpansion limits the number of items a range expression can expand to.
+const maxRangeExpansion = 65536
+
+var (
+	interfaceNamePattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9]*)[_-](\d+(?:/\d+)*)$`)
+	rangePattern         = regexp.MustCompile(`^(.*?)(\d+)-(\d+)$`)
+	trailingNumber       = regexp.MustCompile(`^(.*?)(\d+)$`)
+	digitsOrText         = regexp.MustCompile(`\d+|\D+`)
+)
+
+// ifnameFuncMap returns the interface name and range functions of the templates.
+func ifnameFuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"interfaceParse": interfaceParse,
+		"naturalSort":    naturalSort,
+		"expandRange":    expandRange,
+		"expandVlans":    expandVlans,
+		"compressRange":  compressRange,
+	}
+}
+
+// interfaceParse splits an interface name into its type and numbers.
+// e.g. ifp_0/0/1 -> {"name": "ifp_0/0/1", "type": "ifp", "path": "0/0/1", "numbers": [0 0 1]}
+func interfaceParse(name string) (map[string]interface{}, error) {
+	match := interfaceNamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return nil, fmt.Errorf("invalid interface name %q", name)
+	}
+	numbers := make([]int, 0, 4)
+	for _, part := range strings.Split(match[2], "/") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+	}
+	return map[string]interface{}{
+		"name":    name,
+		"type":    match[1],
+		"path":    match[2],
+		"numbers": numbers,
+	}, nil
+}
+
+// naturalSort sorts names with the numbers in them compared numerically.
+// e.g. [ifp_0/0/10 ifp_0/0/2] -> [ifp_0/0/2 ifp_0/0/10]
+func naturalSort(list interface{}) ([]string, error) {
+	names, err := toStringList(list)
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]string{}, names...)
+	sort.SliceStable(sorted, func(i, j int) bool { return naturalLess(sorted[i], sorted[j]) })
+	return sorted, nil
+}
+
+// expandRange expands a comma separated list of names and ranges, a range ends with a numeric range.
+// e.g. ifp_0/0/1-3,ifp_0/0/8 -> [ifp_0/0/1 ifp_0/0/2 ifp_0/0/3 ifp_0/0/8]
+func expandRange(expression string) ([]string, error) {
+	result := make([]string, 0)
+	for _, item := range strings.Split(expression, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		match := rangePattern.FindStringSubmatch(item)
+		if match == nil {
+			result = append(result, item)
+			continue
+		}
+		from, err := strconv.Atoi(match[2])
+		if err != nil {
+			return nil, err
+		}
+		to, err := strconv.Atoi(match[3])
+		if err != nil {
+			return nil, err
+		}
+		if from > to {
+			return nil, fmt.Errorf("invalid range %q", item)
+		}
+		// Compared as difference, the number of items to-from+1 overflows for the largest numbers.
+		if to-from >= maxRangeExpansion-len(result) {
+			return nil, fmt.Errorf("range %q expands to more than %d items", expression, maxRangeExpansion)
+		}
+		// Keep the width of zero padded numbers, e.g. eth01-10.
+		format := "%s%d"
+		if len(match[2]) > 1 && strings.HasPrefix(match[2], "0") {
+			format = fmt.Sprintf("%%s%%0%dd", len(match[2]))
+		}
+		for i := from; i <= to; i++ {
+			result = append(result, fmt.Sprintf(format, match[1], i))
+		}
+	}
+	return result, nil
+}
+
+// expandVlans expands a VLAN list, e.g. 100-102,200 -> [100 101 102 200]
+func expandVlans(expression string) ([]int, error) {
+	items, err := expandRange(expression)
+	if err != nil {
+		return nil, err
+	}
+	vlans := make([]int, 0, len(items))
+	for _, item := range items {
+		vlan, err := strconv.Atoi(item)
+		if err != nil || vlan < 1 || vlan > 4094 {
+			return nil, fmt.Errorf("invalid VLAN %q in %q", item, expression)
+		}
+		vlans = append(vlans, vlan)
+	}
+	return vlans, nil
+}
+
+// compressRange compresses a list of names or numbers into a comma separated list of ranges.
+// Names are grouped by everything before their last number and sorted naturally.
+// e.g. [ifp_0/0/3 ifp_0/0/1 ifp_0/0/2 ifp_0/0/8] -> ifp_0/0/1-3,ifp_0/0/8 and [100 101 102 200] -> 100-102,200
+func compressRange(list interface{}) (string, error) {
+	names, err := naturalSort(list)
+	if err != nil {
+		return "", err
+	}
+	items := make([]string, 0, len(names))
+	for i := 0; i < len(names); {
+		match := trailingNumber.FindStringSubmatch(names[i])
+		if match == nil || (len(match[2]) > 1 && strings.HasPrefix(match[2], "0")) {
+			items = append(items, names[i])
+			i++
+			continue
+		}
+		from, _ := strconv.Atoi(match[2])
+		to := from
+		j := i + 1
+		for ; j < len(names); j++ {
+			if names[j] == names[j-1] {
+				continue
+			}
+			if names[j] != fmt.Sprintf("%s%d", match[1], to+1) {
+				break
+			}
+			to++
+		}
+		if from == to {
+			items = append(items, names[i])
+		} else {
+			items = append(items, fmt.Sprintf("%s%d-%d", match[1], from, to))
+		}
+		i = j
+	}
+	return strings.Join(items, ","), nil
+}
+
+// naturalLess compares the numbers in the names numerically and all other parts as text.
+func naturalLess(a string, b string) bool {
+	partsA := digitsOrText.FindAllString(a, -1)
+	partsB := digitsOrText.FindAllString(b, -1)
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		if partsA[i] == partsB[i] {
+			continue
+		}
+		if isDigits(partsA[i]) && isDigits(partsB[i]) {
+			numberA := strings.TrimLeft(partsA[i], "0")
+			numberB := strings.TrimLeft(partsB[i], "0")
+			if len(numberA) != len(numberB) {
+				return len(numberA) < len(numberB)
+			}
+			if numberA != numberB {
+				return numberA < numberB
+			}
+			continue
+		}
+		return partsA[i] < partsB[i]
+	}
+	return len(partsA) < len(partsB)
+}
+
+func isDigits(s string) bool {
+	return len(s) > 0 && s[0] >= '0' && s[0] <= '9'
+}
+
+// toStringList converts the lists of templates and of the JSON variables into a list of strings.
+func toStringList(list interface{}) ([]string, error) {
+	switch v := list.(type) {
+	case []string:
+		return v, nil
+	case []int:
+		result := make([]string, 0, len(v))
+		for _, value := range v {
+			result = append(result, strconv.Itoa(value))
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, value := range v {
+			switch item := value.(type) {
+			case string:
+				result = append(result, item)
+			case int:
+				result = append(result, strconv.Itoa(item))
+			case float64:
+				result = append(result, strconv.FormatFloat(item, 'f', -1, 64))
+			default:
+				return nil, fmt.Errorf("invalid list item type: %v", reflect.TypeOf(value))
+			}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("invalid list type: %v", reflect.TypeOf(list))
+}
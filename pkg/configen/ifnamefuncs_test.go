@@ -0,0 +1,133 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterfaceParse(t *testing.T) {
+	got, err := interfaceParse("ifp_0/1/12")
+	require.NoError(t, err)
+	want := map[string]interface{}{"name": "ifp_0/1/12", "type": "ifp", "path": "0/1/12", "numbers": []int{0, 1, 12}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("interfaceParse() mismatch (-want +got):\n%s", diff)
+	}
+	got, err = interfaceParse("ifl-0/0/1/100")
+	require.NoError(t, err)
+	require.Equal(t, "ifl", got["type"])
+	require.Equal(t, []int{0, 0, 1, 100}, got["numbers"])
+
+	for _, name := range []string{"", "ifp", "ifp_", "ifp_0/a", "0/0/1"} {
+		_, err := interfaceParse(name)
+		require.Error(t, err, name)
+	}
+}
+
+func TestNaturalSort(t *testing.T) {
+	got, err := naturalSort([]interface{}{"ifp_0/0/10", "ifp_0/1/1", "ifp_0/0/2", "ifl_0/0/1", "ifp_0/0/02"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"ifl_0/0/1", "ifp_0/0/2", "ifp_0/0/02", "ifp_0/0/10", "ifp_0/1/1"}, got)
+
+	_, err = naturalSort("ifp_0/0/1")
+	require.Error(t, err)
+}
+
+func TestExpandRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       []string
+		wantErr    bool
+	}{
+		{name: "interfaces", expression: "ifp_0/0/1-3", want: []string{"ifp_0/0/1", "ifp_0/0/2", "ifp_0/0/3"}},
+		{name: "list", expression: "ifp_0/0/1-2, ifp_0/0/8,ifp_0/1/1", want: []string{"ifp_0/0/1", "ifp_0/0/2", "ifp_0/0/8", "ifp_0/1/1"}},
+		{name: "dash separator", expression: "ifp-0/0/1", want: []string{"ifp-0/0/1"}},
+		{name: "numbers", expression: "100-102,200", want: []string{"100", "101", "102", "200"}},
+		{name: "zero padded", expression: "eth08-10", want: []string{"eth08", "eth09", "eth10"}},
+		{name: "empty", expression: "", want: []string{}},
+		{name: "reversed", expression: "ifp_0/0/8-1", wantErr: true},
+		{name: "too large", expression: "1-100000", wantErr: true},
+		{name: "too large in total", expression: "1-40000,1-40000", wantErr: true},
+		{name: "overflow", expression: "0-9223372036854775807", wantErr: true},
+		{name: "one more than the limit", expression: "0-65536", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandRange(tt.expression)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandVlans(t *testing.T) {
+	got, err := expandVlans("100-103,200")
+	require.NoError(t, err)
+	require.Equal(t, []int{100, 101, 102, 103, 200}, got)
+
+	for _, expression := range []string{"0", "4095", "100-4095", "vlan100"} {
+		_, err := expandVlans(expression)
+		require.Error(t, err, expression)
+	}
+}
+
+func TestCompressRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "interfaces", list: []string{"ifp_0/0/3", "ifp_0/0/1", "ifp_0/0/2", "ifp_0/0/8"}, want: "ifp_0/0/1-3,ifp_0/0/8"},
+		{name: "slots", list: []string{"ifp_0/1/1", "ifp_0/0/1", "ifp_0/0/2"}, want: "ifp_0/0/1-2,ifp_0/1/1"},
+		{name: "numbers", list: []int{200, 100, 101, 102}, want: "100-102,200"},
+		{name: "json numbers", list: []interface{}{float64(100), float64(101), "200"}, want: "100-101,200"},
+		{name: "duplicates", list: []int{1, 2, 2, 3}, want: "1-3"},
+		{name: "names", list: []string{"lo", "eth"}, want: "eth,lo"},
+		{name: "empty", list: []string{}, want: ""},
+		{name: "invalid item", list: []interface{}{true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compressRange(tt.list)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIfnameFunctionsInTemplate(t *testing.T) {
+	tmpl, err := template.New("t").Funcs(goFuncMap()).Parse(
+		`{{range expandRange .ports}}{{.}} {{end}}{{compressRange (expandVlans .vlans)}} {{(interfaceParse "ifp_0/0/7").path}}`)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, map[string]interface{}{"ports": "ifp_0/0/1-2", "vlans": "10-12,11,20"}))
+	require.Equal(t, "ifp_0/0/1 ifp_0/0/2 10-12,20 0/0/7", buf.String())
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxRenderSteps limits the loop iterations and template calls of a single template execution.
+	maxRenderSteps = 10000000
+	// maxSequenceLength limits the number of elements created by until and untilStep.
+	maxSequenceLength = 1000000
+	// maxRepeatSize limits the size of the strings created by repeat.
+	maxRepeatSize = 16 << 20
+	// renderStepFunc is the function called at the start of each loop iteration and template call.
+	renderStepFunc = "renderStep"
+)
+
+// renderSteps counts the steps of a template execution.
+type renderSteps struct {
+	ctx   context.Context
+	count int
+	limit int
+}
+
+// step stops the execution if the context is done or the execution exceeds the step limit.
+// Loops which neither write output nor call functions can only be stopped here.
+func (s *renderSteps) step() (string, error) {
+	if err := contextError(s.ctx); err != nil {
+		return "", err
+	}
+	s.count++
+	if s.count > s.limit {
+		return "", fmt.Errorf("template execution exceeds %d loop iterations and template calls", s.limit)
+	}
+	return "", nil
+}
+
+// addRenderSteps calls the render step function at the start of each template and each loop iteration.
+func addRenderSteps(templates *template.Template, delimiters Delimiters) error {
+	// The step action {{$renderStep := renderStep}} is parsed with the delimiters of the templates, so that the
+	// parse trees can still be printed. The declaration keeps the empty result out of the output.
+	action := fmt.Sprintf("%s$%s := %s%s", delimiters.Left, renderStepFunc, renderStepFunc, delimiters.Right)
+	trees, err := parse.Parse(renderStepFunc, action, delimiters.Left, delimiters.Right, map[string]interface{}{renderStepFunc: unboundRenderStep})
+	if err != nil {
+		return err
+	}
+	step := trees[renderStepFunc].Root.Nodes[0]
+	for _, t := range templates.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		addRenderStep(t.Tree.Root, step)
+		walkNodes(t.Tree.Root, func(node parse.Node) bool {
+			if rangeNode, ok := node.(*parse.RangeNode); ok && rangeNode.List != nil {
+				addRenderStep(rangeNode.List, step)
+			}
+			return true
+		})
+	}
+	return nil
+}
+
+// addRenderStep adds the step action in front of the list, unless the list already starts with a step action.
+func addRenderStep(list *parse.ListNode, step parse.Node) {
+	if len(list.Nodes) > 0 && list.Nodes[0].String() == step.String() {
+		return
+	}
+	list.Nodes = append([]parse.Node{step}, list.Nodes...)
+}
+
+// unboundRenderStep is replaced by the render step function of each execution.
+func unboundRenderStep() (string, error) {
+	return "", nil
+}
+
+// until replaces the sprig function, which creates sequences of any length.
+func until(count int) ([]int, error) {
+	if count < 0 {
+		return untilStep(0, count, -1)
+	}
+	return untilStep(0, count, 1)
+}
+
+// untilStep replaces the sprig function, which creates sequences of any length and doesn't end if the values overflow.
+func untilStep(start int, stop int, step int) ([]int, error) {
+	if step == 0 || start == stop || (stop > start) != (step > 0) {
+		return []int{}, nil
+	}
+	// The distance is computed unsigned, so that it doesn't overflow for any start and stop.
+	distance, stride := uint64(stop)-uint64(start), uint64(step)
+	if step < 0 {
+		distance, stride = uint64(start)-uint64(stop), -uint64(step)
+	}
+	count := distance / stride
+	if distance%stride != 0 {
+		count++
+	}
+	if count > maxSequenceLength {
+		return nil, fmt.Errorf("sequence of %d elements exceeds %d elements", count, maxSequenceLength)
+	}
+	sequence := make([]int, count)
+	for i := range sequence {
+		sequence[i] = start + i*step
+	}
+	return sequence, nil
+}
+
+// repeat replaces the sprig function, which creates strings of any size.
+func repeat(count int, str string) (string, error) {
+	if count < 0 {
+		return "", fmt.Errorf("negative repeat count %d", count)
+	}
+	if len(str) > 0 && count > maxRepeatSize/len(str) {
+		return "", fmt.Errorf("repeated string exceeds %d bytes", maxRepeatSize)
+	}
+	return strings.Repeat(str, count), nil
+}
+
+// renderWriter collects the output of a template execution.
+// It stops the execution if the context is done or the output gets too large.
+type renderWriter struct {
+	ctx     context.Context
+	maxSize int64
+	mutex   sync.Mutex
+	buffer  bytes.Buffer
+	closed  bool
+}
+
+func (w *renderWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if err := contextError(w.ctx); err != nil {
+		return 0, err
+	}
+	if w.closed {
+		return 0, ErrRenderCanceled
+	}
+	if w.maxSize > 0 && int64(w.buffer.Len()+len(p)) > w.maxSize {
+		return 0, errors.WithMessagef(ErrOutputTooLarge, "output exceeds %d bytes", w.maxSize)
+	}
+	return w.buffer.Write(p)
+}
+
+// close returns the output and rejects all further writes of executions which didn't stop in time.
+func (w *renderWriter) close() []byte {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	return w.buffer.Bytes()
+}
+
+// contextError maps the error of a done context to the render errors.
+func contextError(ctx context.Context) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return ErrRenderTimeout
+	case context.Canceled:
+		return ErrRenderCanceled
+	}
+	return nil
+}
+
+// stricterTimeout returns the shorter of both timeouts, 0 is no timeout.
+func stricterTimeout(a time.Duration, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// stricterSize returns the smaller of both sizes, 0 is no limit.
+func stricterSize(a int64, b int64) int64 {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
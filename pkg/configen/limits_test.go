@@ -0,0 +1,201 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_GenerateFileLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name           string
+		templateFolder string
+		options        []Option
+		ctx            context.Context
+		want           string
+		wantedErr      error
+	}{
+		{
+			name:           "template render timeout",
+			templateFolder: "g19",
+			wantedErr:      ErrRenderTimeout,
+		}, {
+			name:           "repository render timeout",
+			templateFolder: "g19",
+			options:        []Option{WithRenderTimeout(10 * time.Millisecond)},
+			wantedErr:      ErrRenderTimeout,
+		}, {
+			name:           "template max output size",
+			templateFolder: "g20",
+			wantedErr:      ErrOutputTooLarge,
+		}, {
+			name:           "repository max output size",
+			templateFolder: "g2",
+			options:        []Option{WithMaxOutputSize(5)},
+			wantedErr:      ErrOutputTooLarge,
+		}, {
+			name:           "output within limits",
+			templateFolder: "g2",
+			options:        []Option{WithMaxOutputSize(100), WithRenderTimeout(time.Minute)},
+			want:           "Hi Chris!\nfooter",
+		}, {
+			name:           "canceled",
+			templateFolder: "g2",
+			ctx:            canceled,
+			wantedErr:      ErrRenderCanceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			r := NewRepository("testdata/templates", tt.options...)
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			got, _, err := r.GenerateFile(ctx, tt.templateFolder, map[string]interface{}{"name": "Chris"})
+			if tt.wantedErr != nil {
+				is.True(errors.Is(err, tt.wantedErr), "error = %v, wantedErr %v", err, tt.wantedErr)
+				return
+			}
+			is.NoError(err)
+			is.Equal(tt.want, string(got))
+		})
+	}
+}
+
+func TestRepository_GenerateFileStopsLoops(t *testing.T) {
+	is := require.New(t)
+	config := []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n")
+	r := NewStoreRepository(NewMemoryStore(map[string][]byte{
+		// The loops neither write output nor call functions.
+		"endless/config.yaml": config,
+		"endless/main.gotext": []byte(`{{$l := until 1000000}}{{range $l}}{{range $l}}{{end}}{{end}}`),
+	}))
+	goroutines := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err := r.GenerateFile(ctx, "endless", nil)
+	is.True(errors.Is(err, ErrRenderTimeout), "error = %v", err)
+	// The execution stops at the next loop iteration.
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	is.LessOrEqual(runtime.NumGoroutine(), goroutines)
+}
+
+func Test_renderSteps(t *testing.T) {
+	is := require.New(t)
+	steps := &renderSteps{ctx: context.Background(), limit: 2}
+	for i := 0; i < 2; i++ {
+		_, err := steps.step()
+		is.NoError(err)
+	}
+	_, err := steps.step()
+	is.EqualError(err, "template execution exceeds 2 loop iterations and template calls")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = (&renderSteps{ctx: canceled, limit: 2}).step()
+	is.True(errors.Is(err, ErrRenderCanceled))
+}
+
+func Test_addRenderSteps(t *testing.T) {
+	is := require.New(t)
+	templates := template.Must(template.New("main").Parse(`{{define "item"}}{{.}}{{end}}{{range .}}{{template "item" .}}{{end}}`))
+	is.NoError(addRenderSteps(templates, defaultDelimiters))
+	is.NoError(addRenderSteps(templates, defaultDelimiters))
+	is.Equal(`{{$renderStep := renderStep}}{{range .}}{{$renderStep := renderStep}}{{template "item" .}}{{end}}`, templates.Tree.Root.String())
+	is.Equal(`{{$renderStep := renderStep}}{{.}}`, templates.Lookup("item").Tree.Root.String())
+
+	// The main template, two iterations and two template calls.
+	steps := &renderSteps{ctx: context.Background(), limit: 5}
+	var output strings.Builder
+	is.NoError(templates.Funcs(template.FuncMap{renderStepFunc: steps.step}).Execute(&output, []int{1, 2}))
+	is.Equal("12", output.String())
+	is.Equal(5, steps.count)
+}
+
+func Test_untilStep(t *testing.T) {
+	is := require.New(t)
+	for _, tt := range []struct {
+		start, stop, step int
+		want              []int
+	}{
+		{start: 0, stop: 5, step: 2, want: []int{0, 2, 4}},
+		{start: 5, stop: 0, step: -2, want: []int{5, 3, 1}},
+		{start: 0, stop: 5, step: -1, want: []int{}},
+		{start: 0, stop: 5, step: 0, want: []int{}},
+		{start: math.MaxInt64 - 2, stop: math.MaxInt64, step: math.MaxInt64, want: []int{math.MaxInt64 - 2}},
+		{start: math.MinInt64 + 2, stop: math.MinInt64, step: math.MinInt64, want: []int{math.MinInt64 + 2}},
+	} {
+		got, err := untilStep(tt.start, tt.stop, tt.step)
+		is.NoError(err)
+		is.Equal(tt.want, got)
+	}
+	_, err := untilStep(math.MinInt64, math.MaxInt64, 1)
+	is.Error(err)
+	_, err = until(maxSequenceLength + 1)
+	is.Error(err)
+	got, err := until(-3)
+	is.NoError(err)
+	is.Equal([]int{0, -1, -2}, got)
+}
+
+func Test_repeat(t *testing.T) {
+	is := require.New(t)
+	got, err := repeat(3, "ab")
+	is.NoError(err)
+	is.Equal("ababab", got)
+	_, err = repeat(maxRepeatSize, "ab")
+	is.Error(err)
+	_, err = repeat(-1, "ab")
+	is.Error(err)
+	got, err = repeat(math.MaxInt64, "")
+	is.NoError(err)
+	is.Equal("", got)
+}
+
+func Test_stricterTimeout(t *testing.T) {
+	is := require.New(t)
+	is.Equal(time.Duration(0), stricterTimeout(0, 0))
+	is.Equal(time.Second, stricterTimeout(0, time.Second))
+	is.Equal(time.Second, stricterTimeout(time.Second, 0))
+	is.Equal(time.Second, stricterTimeout(time.Minute, time.Second))
+	is.Equal(time.Second, stricterTimeout(time.Second, time.Minute))
+}
+
+func Test_stricterSize(t *testing.T) {
+	is := require.New(t)
+	is.Equal(int64(0), stricterSize(0, 0))
+	is.Equal(int64(10), stricterSize(0, 10))
+	is.Equal(int64(10), stricterSize(10, 0))
+	is.Equal(int64(10), stricterSize(10, 20))
+	is.Equal(int64(10), stricterSize(20, 10))
+}
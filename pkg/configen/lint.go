@@ -0,0 +1,420 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// LintError is the severity of issues which let the generation fail
+	LintError = "error"
+	// LintWarning is the severity of issues which point to likely mistakes
+	LintWarning = "warning"
+)
+
+// maxUnknownFunctions limits the unknown functions reported for a single template file.
+const maxUnknownFunctions = 100
+
+var (
+	unknownFunctionPattern = regexp.MustCompile(`function "([^"]+)" not defined`)
+	nodeLocationPattern    = regexp.MustCompile(`^(.*):(\d+):(\d+)$`)
+)
+
+// LintIssue is a problem of a template found by the linter.
+type LintIssue struct {
+	// Severity of the issue
+	Severity string `json:"severity" enums:"error,warning"`
+	// Rule is the check which found the issue (e.g. "undefined-template")
+	Rule string `json:"rule"`
+	// Message describes the issue
+	Message string `json:"message"`
+	// File is the file of the issue relative to the template path
+	File string `json:"file,omitempty"`
+	// Line of the issue, starting with 1. 0 if the line is unknown.
+	Line int `json:"line,omitempty"`
+	// Column of the issue. 0 if the column is unknown.
+	Column int `json:"column,omitempty"`
+}
+
+// LintResult lists the issues of a template.
+type LintResult struct {
+	// Name of the template
+	Name string `json:"name"`
+	// Version of the template, if the template is versioned.
+	Version string `json:"version,omitempty"`
+	// Valid is set if no issue is an error.
+	Valid bool `json:"valid"`
+	// Issues of the template, sorted by file and line.
+	Issues []LintIssue `json:"issues"`
+}
+
+// LintTemplate checks the config and the template files of the template folder without generating a file.
+// If variables are given, all outputs are rendered with them as dry run and render errors are reported as issues.
+// Only a missing template is returned as error, all other problems are reported as issues.
+func (r *Repository) LintTemplate(ctx context.Context, templateFolder string, variables map[string]interface{}) (*LintResult, error) {
+	result, config, err := r.lintTemplate(templateFolder)
+	if err != nil {
+		return nil, err
+	}
+	if variables != nil && !result.hasErrors() {
+		if _, err := r.GenerateBundle(ctx, templateFolder, variables); err != nil {
+			result.Issues = append(result.Issues, r.renderIssue(err, config))
+		}
+	}
+	result.Valid = !result.hasErrors()
+	sort.SliceStable(result.Issues, func(i, j int) bool {
+		if result.Issues[i].File != result.Issues[j].File {
+			return result.Issues[i].File < result.Issues[j].File
+		}
+		return result.Issues[i].Line < result.Issues[j].Line
+	})
+	return result, nil
+}
+
+func (r *Repository) lintTemplate(templateFolder string) (*LintResult, *TemplateConfig, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if _, err := readConfigFile(r.store, templateFolder); errors.Is(err, ErrTemplateConfigNotFound) {
+		return nil, nil, err
+	}
+	l := &linter{store: r.store, result: &LintResult{Issues: []LintIssue{}}}
+	l.result.Name, l.result.Version = splitVersion(r.store, templateFolder)
+	configFile := fmt.Sprintf("%s/config.yaml", templateFolder)
+	l.checkConfigKeys(configFile)
+	config, err := parseConfigFile(r.store, templateFolder)
+	if err != nil {
+		l.add(LintError, "invalid-config", err.Error(), configFile, 0, 0)
+		return l.result, nil, nil
+	}
+	config.sandbox = r.sandbox
+	config.store = r.store
+	if config.data, err = readData(r.store, dataFolders(templateFolder, config.parents)); err != nil {
+		l.add(LintError, "invalid-data", err.Error(), "", 0, 0)
+	}
+	if !l.checkPatterns(config) {
+		return l.result, config, nil
+	}
+	if len(config.TemplateEngine) > 0 && config.TemplateEngine != EngineGolang {
+		// Only the parse errors of other engines are known.
+		engine, err := newEngine(config)
+		if err == nil {
+			_, err = engine.Parse(config)
+		}
+		if err != nil {
+			l.add(LintError, "parse-error", err.Error(), "", 0, 0)
+		}
+		return l.result, config, nil
+	}
+	l.checkGoTemplates(config)
+	return l.result, config, nil
+}
+
+// hasErrors checks whether one of the issues is an error.
+func (r *LintResult) hasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+// renderIssue converts the error of a dry run into an issue.
+func (r *Repository) renderIssue(err error, config *TemplateConfig) LintIssue {
+	issue := LintIssue{Severity: LintError, Rule: "render-error", Message: err.Error()}
+	var renderError *RenderError
+	var missingKeyError *MissingKeyError
+	switch {
+	case errors.As(err, &renderError):
+		issue.File, issue.Line, issue.Column = renderError.Template, renderError.Line, renderError.Column
+	case errors.As(err, &missingKeyError):
+		issue.Rule = "missing-key"
+		issue.File, issue.Line, issue.Column = missingKeyError.Template, missingKeyError.Line, missingKeyError.Column
+	case errors.Is(err, ErrInvalidVariables):
+		issue.Rule = "invalid-variables"
+	case errors.Is(err, ErrInvalidOutput):
+		issue.Rule = "invalid-output"
+	}
+	if len(issue.File) > 0 {
+		issue.File = r.templateFilePath(config, issue.File)
+	}
+	return issue
+}
+
+// templateFilePath returns the path of a template file in the template store.
+// The template engine only knows the file names, files of later patterns replace files of earlier patterns.
+func (r *Repository) templateFilePath(config *TemplateConfig, name string) string {
+	filePath := name
+	for _, pattern := range config.Patterns() {
+		file := path.Join(path.Dir(pattern), name)
+		if matched, _ := path.Match(pattern, file); matched && stampFile(r.store, file).exists {
+			filePath = file
+		}
+	}
+	return filePath
+}
+
+// linter collects the issues of a template.
+type linter struct {
+	store  TemplateStore
+	result *LintResult
+}
+
+// add adds an issue of a file of the template store.
+func (l *linter) add(severity string, rule string, message string, file string, line int, column int) {
+	l.result.Issues = append(l.result.Issues, LintIssue{
+		Severity: severity,
+		Rule:     rule,
+		Message:  message,
+		File:     file,
+		Line:     line,
+		Column:   column,
+	})
+}
+
+// checkConfigKeys reports the keys of the config file which are not attributes of the template config.
+func (l *linter) checkConfigKeys(configFile string) {
+	content, err := fs.ReadFile(l.store, configFile)
+	if err != nil {
+		return
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil || len(document.Content) == 0 {
+		// Syntax errors are reported as invalid config.
+		return
+	}
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return
+	}
+	known := configKeys()
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key := mapping.Content[i]
+		if !known[key.Value] {
+			l.add(LintWarning, "unknown-config-key", fmt.Sprintf("unknown attribute %s", key.Value), configFile, key.Line, key.Column)
+		}
+	}
+}
+
+// configKeys returns the yaml attributes of the template config.
+func configKeys() map[string]bool {
+	keys := make(map[string]bool)
+	configType := reflect.TypeOf(TemplateConfig{})
+	for i := 0; i < configType.NumField(); i++ {
+		name := strings.Split(configType.Field(i).Tag.Get("yaml"), ",")[0]
+		if len(name) > 0 && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// checkPatterns reports patterns matching no file and main templates which are not matched by the main patterns.
+// It returns false if the templates can't be parsed at all.
+func (l *linter) checkPatterns(config *TemplateConfig) bool {
+	mainPatterns := append([]string{}, config.inheritedPatterns...)
+	if len(config.MainPattern) > 0 {
+		mainPatterns = append(mainPatterns, config.MainPattern)
+	}
+	if len(mainPatterns) == 0 {
+		l.add(LintError, "invalid-config", "main_pattern is not set", "", 0, 0)
+		return false
+	}
+	for _, pattern := range []string{config.MainPattern, config.IncludePattern} {
+		if len(pattern) == 0 {
+			continue
+		}
+		files, err := fs.Glob(l.store, pattern)
+		if err != nil {
+			l.add(LintError, "invalid-pattern", fmt.Sprintf("pattern %s: %v", pattern, err), "", 0, 0)
+			return false
+		}
+		if len(files) == 0 {
+			severity := LintWarning
+			if pattern == config.MainPattern {
+				severity = LintError
+			}
+			l.add(severity, "empty-pattern", fmt.Sprintf("pattern %s matches no file", pattern), "", 0, 0)
+		}
+	}
+	for _, output := range config.resolvedOutputs() {
+		if len(output.MainTemplate) == 0 {
+			l.add(LintError, "main-template-not-found", "main_template is not set", "", 0, 0)
+			continue
+		}
+		if found, _ := matchesTemplate(l.store, mainPatterns, output.MainTemplate); !found {
+			l.add(LintError, "main-template-not-found", fmt.Sprintf("main_template %s is not matched by main_pattern", output.MainTemplate), "", 0, 0)
+		}
+	}
+	return true
+}
+
+// templateFile is a file of a template set.
+type templateFile struct {
+	path      string
+	inherited bool
+	include   bool
+	templates *template.Template
+}
+
+// checkGoTemplates parses each file of the template set on its own and checks the template calls and defines.
+func (l *linter) checkGoTemplates(config *TemplateConfig) {
+	funcs := goFuncMap()
+	funcs["lookup"] = dataLookup(config.data)
+	// files maps the file names to the files, later patterns replace files of earlier patterns like the engine does.
+	files := make(map[string]*templateFile)
+	var names []string
+	for _, pattern := range config.Patterns() {
+		if pattern != config.MainPattern {
+			if err := checkDelimiters(l.store, pattern, config.delimiters()); err != nil {
+				l.add(LintError, "invalid-delimiters", err.Error(), "", 0, 0)
+			}
+		}
+		matches, _ := fs.Glob(l.store, pattern)
+		for _, filePath := range matches {
+			name := path.Base(filePath)
+			if _, ok := files[name]; !ok {
+				names = append(names, name)
+			}
+			files[name] = &templateFile{
+				path:      filePath,
+				inherited: containsString(config.inheritedPatterns, pattern),
+				include:   pattern == config.IncludePattern,
+			}
+		}
+	}
+	defined := make(map[string]*templateFile)
+	definedBy := make(map[string][]string)
+	for _, name := range names {
+		file := files[name]
+		file.templates = l.parseFile(name, file.path, config, funcs)
+		if file.templates == nil {
+			continue
+		}
+		if err := checkFunctions(file.templates, config, funcs); err != nil {
+			l.add(LintError, "function-not-allowed", err.Error(), file.path, 0, 0)
+		}
+		defined[name] = file
+		for _, t := range file.templates.Templates() {
+			if t.Name() == name || t.Tree == nil {
+				continue
+			}
+			if !file.inherited {
+				definedBy[t.Name()] = append(definedBy[t.Name()], file.path)
+			}
+			defined[t.Name()] = file
+		}
+	}
+	called := make(map[string]bool)
+	for _, name := range names {
+		file := files[name]
+		if file.templates == nil {
+			continue
+		}
+		for _, t := range file.templates.Templates() {
+			if t.Tree == nil || t.Tree.Root == nil {
+				continue
+			}
+			tree := t.Tree
+			walkNodes(tree.Root, func(node parse.Node) bool {
+				call, ok := node.(*parse.TemplateNode)
+				if !ok {
+					return true
+				}
+				called[call.Name] = true
+				if _, ok := defined[call.Name]; !ok {
+					location, _ := tree.ErrorContext(call)
+					line, column := nodeLocation(location)
+					l.add(LintError, "undefined-template", fmt.Sprintf("template %q is not defined", call.Name), file.path, line, column)
+				}
+				return true
+			})
+		}
+	}
+	defines := make([]string, 0, len(definedBy))
+	for name := range definedBy {
+		defines = append(defines, name)
+	}
+	sort.Strings(defines)
+	for _, name := range defines {
+		paths := definedBy[name]
+		if len(paths) > 1 {
+			l.add(LintWarning, "duplicate-define", fmt.Sprintf("template %q is defined in %s, the last definition is used", name,
+				strings.Join(paths, " and ")), paths[len(paths)-1], 0, 0)
+		}
+		// Defines of shared include files are used by other templates.
+		if file := defined[name]; !called[name] && !file.include && !file.inherited {
+			l.add(LintWarning, "unused-define", fmt.Sprintf("template %q is never called", name), file.path, 0, 0)
+		}
+	}
+}
+
+// parseFile parses a single template file and reports unknown functions and parse errors.
+// Unknown functions are replaced by stubs, so that all unknown functions of the file are reported.
+func (l *linter) parseFile(name string, filePath string, config *TemplateConfig, funcs template.FuncMap) *template.Template {
+	content, err := fs.ReadFile(l.store, filePath)
+	if err != nil {
+		l.add(LintError, "parse-error", err.Error(), filePath, 0, 0)
+		return nil
+	}
+	stubs := template.FuncMap{}
+	for i := 0; i < maxUnknownFunctions; i++ {
+		delimiters := config.delimiters()
+		t := template.New(name).Funcs(funcs).Funcs(stubs).Delims(delimiters.Left, delimiters.Right)
+		_, err := t.Parse(string(content))
+		if err == nil {
+			return t
+		}
+		renderError := newRenderError(err, l.store, map[string]string{name: filePath})
+		if renderError == nil {
+			l.add(LintError, "parse-error", err.Error(), filePath, 0, 0)
+			return nil
+		}
+		if match := unknownFunctionPattern.FindStringSubmatch(renderError.Message); match != nil {
+			l.add(LintError, "unknown-function", fmt.Sprintf("function %s is not defined", match[1]), filePath, renderError.Line, renderError.Column)
+			stubs[match[1]] = func(...interface{}) interface{} { return nil }
+			continue
+		}
+		l.add(LintError, "parse-error", renderError.Message, filePath, renderError.Line, renderError.Column)
+		return nil
+	}
+	return nil
+}
+
+// nodeLocation returns the line and column of a node location (e.g. "main.gotext:3:12").
+func nodeLocation(location string) (int, int) {
+	match := nodeLocationPattern.FindStringSubmatch(location)
+	if match == nil {
+		return 0, 0
+	}
+	line, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+	return line, column
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_LintTemplate(t *testing.T) {
+	tests := []struct {
+		templateFolder string
+		variables      map[string]interface{}
+		want           *LintResult
+	}{
+		{
+			templateFolder: "g2",
+			want:           &LintResult{Name: "g2", Valid: true, Issues: []LintIssue{}},
+		},
+		{
+			templateFolder: "l1",
+			want: &LintResult{Name: "l1", Valid: false, Issues: []LintIssue{
+				{Severity: LintWarning, Rule: "empty-pattern", Message: "pattern l1_includes/*.gotext matches no file"},
+				{Severity: LintWarning, Rule: "unknown-config-key", Message: "unknown attribute main_templat", File: "l1/config.yaml", Line: 4, Column: 1},
+				{Severity: LintWarning, Rule: "duplicate-define", Message: `template "header" is defined in l1/header.gotext and l1/layout.gotext, the last definition is used`, File: "l1/layout.gotext"},
+				{Severity: LintWarning, Rule: "unused-define", Message: `template "unused" is never called`, File: "l1/main.gotext"},
+				{Severity: LintError, Rule: "undefined-template", Message: `template "missing" is not defined`, File: "l1/main.gotext", Line: 2, Column: 11},
+				{Severity: LintError, Rule: "unknown-function", Message: "function frobnicate is not defined", File: "l1/main.gotext", Line: 3},
+			}},
+		},
+		{
+			templateFolder: "t14",
+			want: &LintResult{Name: "t14", Valid: false, Issues: []LintIssue{
+				{Severity: LintError, Rule: "parse-error", Message: "unexpected {{end}}", File: "t14/main.gotext", Line: 2},
+			}},
+		},
+		{
+			templateFolder: "g12",
+			variables:      map[string]interface{}{"name": "Chris", "device": map[string]interface{}{}},
+			want: &LintResult{Name: "g12", Valid: false, Issues: []LintIssue{
+				{Severity: LintError, Rule: "missing-key", Message: `main.gotext: missing key "site" of <.device.site> in template site.gotext at line 1, column 32`, File: "g12/site.gotext", Line: 1, Column: 32},
+			}},
+		},
+		{
+			templateFolder: "g12",
+			variables:      map[string]interface{}{"name": "Chris", "device": map[string]interface{}{"site": "fra1"}},
+			want:           &LintResult{Name: "g12", Valid: true, Issues: []LintIssue{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.templateFolder, func(t *testing.T) {
+			is := require.New(t)
+			r := NewRepository("testdata/templates")
+			got, err := r.LintTemplate(context.Background(), tt.templateFolder, tt.variables)
+			is.NoError(err)
+			is.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestRepository_LintTemplateErrors(t *testing.T) {
+	is := require.New(t)
+	r := NewRepository("testdata/templates")
+	_, err := r.LintTemplate(context.Background(), "missing", nil)
+	is.True(errors.Is(err, ErrTemplateConfigNotFound))
+
+	got, err := r.LintTemplate(context.Background(), "t13", nil)
+	is.NoError(err)
+	is.False(got.Valid)
+	is.Len(got.Issues, 1)
+	is.Equal("invalid-config", got.Issues[0].Rule)
+	is.Equal("t13/config.yaml", got.Issues[0].File)
+}
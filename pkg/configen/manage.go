@@ -0,0 +1,172 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"io/fs"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// PutTemplate creates or replaces a template with the given files.
+// The files map the path inside the template folder to the file content.
+// The files are validated before the template is activated, the activation itself is atomic
+// for running generations. The result reports whether the template was newly created.
+func (r *Repository) PutTemplate(templateName string, files map[string][]byte) (bool, error) {
+	if !isValidTemplateName(templateName) {
+		return false, errors.WithMessage(ErrInvalidTemplateName, templateName)
+	}
+	store, ok := r.store.(WritableTemplateStore)
+	if !ok {
+		return false, ErrReadOnlyStore
+	}
+	cleanedFiles := make(map[string][]byte, len(files))
+	for filePath, content := range files {
+		cleaned, err := cleanFilePath(filePath)
+		if err != nil {
+			return false, err
+		}
+		cleanedFiles[cleaned] = content
+	}
+	if err := validateTemplate(newOverlayStore(r.store, templateName, cleanedFiles), templateName, r.sandbox); err != nil {
+		return false, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := checkTemplateConflicts(r.store, templateName); err != nil {
+		return false, err
+	}
+	created, err := store.ReplaceFolder(templateName, cleanedFiles)
+	if err != nil {
+		return false, err
+	}
+	r.cache.invalidate()
+	log.Info().Str("template", templateName).Bool("created", created).Msg("template activated")
+	return created, nil
+}
+
+// DeleteTemplate removes a template from the template folder.
+func (r *Repository) DeleteTemplate(templateName string) error {
+	if !isValidTemplateName(templateName) {
+		return errors.WithMessage(ErrInvalidTemplateName, templateName)
+	}
+	store, ok := r.store.(WritableTemplateStore)
+	if !ok {
+		return ErrReadOnlyStore
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, err := readConfigFile(r.store, templateName); err != nil {
+		return err
+	}
+	if err := checkTemplateConflicts(r.store, templateName); err != nil {
+		return err
+	}
+	if err := store.RemoveFolder(templateName); err != nil {
+		return err
+	}
+	r.cache.invalidate()
+	log.Info().Str("template", templateName).Msg("template deleted")
+	return nil
+}
+
+// checkTemplateConflicts rejects template folders inside other templates and template folders containing other templates.
+// Replacing or removing such a folder would also change the other templates, e.g. all versions of a versioned template.
+func checkTemplateConflicts(store TemplateStore, templateFolder string) error {
+	for parent := path.Dir(templateFolder); parent != "."; parent = path.Dir(parent) {
+		if isTemplateFolder(store, parent) {
+			return errors.WithMessagef(ErrTemplateConflict, "%s is inside the template %s", templateFolder, parent)
+		}
+	}
+	err := fs.WalkDir(store, templateFolder, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && filePath != templateFolder && isTemplateFolder(store, filePath) {
+			return errors.WithMessagef(ErrTemplateConflict, "%s contains the template %s", templateFolder, filePath)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// validateTemplate checks that the config of the template folder is valid and all templates can be parsed.
+func validateTemplate(store TemplateStore, templateFolder string, sandbox bool) error {
+	config, err := parseConfigFile(store, templateFolder)
+	if err != nil {
+		return errors.WithMessage(ErrInvalidTemplate, err.Error())
+	}
+	config.sandbox = sandbox
+	config.store = store
+	if config.data, err = readData(store, dataFolders(templateFolder, config.parents)); err != nil {
+		return errors.WithMessage(ErrInvalidTemplate, err.Error())
+	}
+	engine, err := newEngine(config)
+	if err != nil {
+		return errors.WithMessage(ErrInvalidTemplate, err.Error())
+	}
+	mainPatterns := config.inheritedPatterns
+	if len(config.MainPattern) > 0 {
+		mainPatterns = append(append([]string{}, mainPatterns...), config.MainPattern)
+	}
+	for _, output := range config.resolvedOutputs() {
+		if len(output.MainTemplate) == 0 {
+			return errors.WithMessage(ErrInvalidTemplate, "main_template is not set")
+		}
+		if len(mainPatterns) == 0 {
+			continue
+		}
+		found, err := matchesTemplate(store, mainPatterns, output.MainTemplate)
+		if err != nil {
+			return errors.WithMessage(ErrInvalidTemplate, err.Error())
+		}
+		if !found {
+			return errors.WithMessagef(ErrInvalidTemplate, "main_template %s is not matched by main_pattern", output.MainTemplate)
+		}
+	}
+	if _, err := engine.Parse(config); err != nil {
+		return errors.WithMessage(ErrInvalidTemplate, err.Error())
+	}
+	if len(config.VariablesSchema) > 0 {
+		if _, err := readSchemaFile(store, config.VariablesSchema); err != nil {
+			return errors.WithMessage(ErrInvalidTemplate, err.Error())
+		}
+	}
+	return nil
+}
+
+// matchesTemplate checks whether one of the patterns matches a file with the template name.
+func matchesTemplate(store TemplateStore, patterns []string, templateName string) (bool, error) {
+	for _, pattern := range patterns {
+		files, err := fs.Glob(store, pattern)
+		if err != nil {
+			return false, err
+		}
+		for _, file := range files {
+			if path.Base(file) == templateName {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_PutTemplate(t *testing.T) {
+	config := []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n")
+	tests := []struct {
+		name      string
+		files     map[string][]byte
+		wantedErr error
+	}{
+		{
+			name:  "valid template",
+			files: map[string][]byte{"config.yaml": config, "main.gotext": []byte("Hi {{.name}}!")},
+		}, {
+			name:      "missing config",
+			files:     map[string][]byte{"main.gotext": []byte("Hi {{.name}}!")},
+			wantedErr: ErrInvalidTemplate,
+		}, {
+			name:      "main template not matched by main pattern",
+			files:     map[string][]byte{"config.yaml": config, "main.goyaml": []byte("Hi {{.name}}!")},
+			wantedErr: ErrInvalidTemplate,
+		}, {
+			name:      "template does not parse",
+			files:     map[string][]byte{"config.yaml": config, "main.gotext": []byte("Hi {{.name}!")},
+			wantedErr: ErrInvalidTemplate,
+		}, {
+			name:      "unknown template function",
+			files:     map[string][]byte{"config.yaml": config, "main.gotext": []byte("Hi {{unknown .name}}!")},
+			wantedErr: ErrInvalidTemplate,
+		}, {
+			name:      "file outside of the template folder",
+			files:     map[string][]byte{"config.yaml": config, "main.gotext": []byte("Hi"), "../escape.gotext": []byte("")},
+			wantedErr: ErrInvalidTemplate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			templatePath, err := ioutil.TempDir("", "templates")
+			is.NoError(err)
+			defer func() { _ = os.RemoveAll(templatePath) }()
+			r := NewRepository(templatePath)
+
+			created, err := r.PutTemplate("t", tt.files)
+			if tt.wantedErr != nil {
+				if !errors.Is(err, tt.wantedErr) {
+					t.Errorf("PutTemplate() error = %v, wantedErr %v", err, tt.wantedErr)
+				}
+				_, err = r.Template("t", "")
+				is.True(errors.Is(err, ErrTemplateConfigNotFound))
+				return
+			}
+			is.NoError(err)
+			is.True(created)
+			info, err := os.Stat(filepath.Join(templatePath, "t"))
+			is.NoError(err)
+			is.Equal(os.FileMode(0755), info.Mode().Perm())
+			got, _, err := r.GenerateFile(context.Background(), "t", map[string]interface{}{"name": "Chris"})
+			is.NoError(err)
+			is.Equal("Hi Chris!", string(got))
+		})
+	}
+}
+
+func TestRepository_PutTemplate_Replace(t *testing.T) {
+	is := require.New(t)
+	templatePath, err := ioutil.TempDir("", "templates")
+	is.NoError(err)
+	defer func() { _ = os.RemoveAll(templatePath) }()
+	r := NewRepository(templatePath)
+
+	config := []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n")
+	_, err = r.PutTemplate("t", map[string][]byte{"config.yaml": config, "main.gotext": []byte("v1")})
+	is.NoError(err)
+	created, err := r.PutTemplate("t", map[string][]byte{"config.yaml": config, "main.gotext": []byte("v2")})
+	is.NoError(err)
+	is.False(created)
+	got, _, err := r.GenerateFile(context.Background(), "t", nil)
+	is.NoError(err)
+	is.Equal("v2", string(got))
+
+	_, err = r.PutTemplate("t", map[string][]byte{"config.yaml": config, "main.gotext": []byte("{{")})
+	is.True(errors.Is(err, ErrInvalidTemplate))
+	got, _, err = r.GenerateFile(context.Background(), "t", nil)
+	is.NoError(err)
+	is.Equal("v2", string(got))
+
+	is.NoError(r.DeleteTemplate("t"))
+	_, _, err = r.GenerateFile(context.Background(), "t", nil)
+	is.True(errors.Is(err, ErrTemplateConfigNotFound))
+	is.True(errors.Is(r.DeleteTemplate("t"), ErrTemplateConfigNotFound))
+	is.True(errors.Is(r.DeleteTemplate(".."), ErrInvalidTemplateName))
+}
+
+func TestRepository_PutTemplate_Conflicts(t *testing.T) {
+	is := require.New(t)
+	config := []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n")
+	store := NewMemoryStore(map[string][]byte{
+		"v/1.0.0/config.yaml": config,
+		"v/1.0.0/main.gotext": []byte("v1"),
+		"u/config.yaml":       config,
+		"u/main.gotext":       []byte("u"),
+	})
+	r := NewStoreRepository(store)
+	files := map[string][]byte{"config.yaml": config, "main.gotext": []byte("new")}
+
+	// An unversioned upload must not replace all versions.
+	_, err := r.PutTemplate("v", files)
+	is.True(errors.Is(err, ErrTemplateConflict))
+	got, _, err := r.GenerateFile(context.Background(), "v/1.0.0", nil)
+	is.NoError(err)
+	is.Equal("v1", string(got))
+
+	// Versions can't be added to an unversioned template.
+	_, err = r.PutTemplate("u/1.0.0", files)
+	is.True(errors.Is(err, ErrTemplateConflict))
+	got, _, err = r.GenerateFile(context.Background(), "u", nil)
+	is.NoError(err)
+	is.Equal("u", string(got))
+
+	created, err := r.PutTemplate("v/2.0.0", files)
+	is.NoError(err)
+	is.True(created)
+	is.NoError(r.DeleteTemplate("v/1.0.0"))
+}
+
+func TestReadTarGz(t *testing.T) {
+	is := require.New(t)
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	is.NoError(tarWriter.WriteHeader(&tar.Header{Name: "data/", Typeflag: tar.TypeDir, Mode: 0755}))
+	is.NoError(tarWriter.WriteHeader(&tar.Header{Name: "./config.yaml", Typeflag: tar.TypeReg, Mode: 0644, Size: 6}))
+	_, err := tarWriter.Write([]byte("engine"))
+	is.NoError(err)
+	is.NoError(tarWriter.Close())
+	is.NoError(gzipWriter.Close())
+
+	files, err := ReadTarGz(&buffer)
+	is.NoError(err)
+	is.Equal(map[string][]byte{"./config.yaml": []byte("engine")}, files)
+
+	_, err = ReadTarGz(bytes.NewReader([]byte("no archive")))
+	is.True(errors.Is(err, ErrInvalidTemplate))
+}
+
+func Test_readArchiveLimit(t *testing.T) {
+	is := require.New(t)
+	files := map[string][]byte{"a": bytes.Repeat([]byte("x"), 6), "b": bytes.Repeat([]byte("x"), 6)}
+
+	got, err := readTar(bytes.NewReader(writeTar(is, files)), 12)
+	is.NoError(err)
+	is.Equal(files, got)
+	_, err = readTar(bytes.NewReader(writeTar(is, files)), 11)
+	is.True(errors.Is(err, ErrInvalidTemplate))
+
+	got, err = readZip(writeZip(is, files), 12)
+	is.NoError(err)
+	is.Equal(files, got)
+	_, err = readZip(writeZip(is, files), 11)
+	is.True(errors.Is(err, ErrInvalidTemplate))
+}
@@ -25,6 +25,64 @@ var (
 	ErrTemplateConfigNotFound = errors.New("template config not found")
 	//ErrPostProcessorNotFound engine not found
 	ErrPostProcessorNotFound = errors.New("post processor not found")
+	//ErrInvalidPostProcessorArgs post processor arguments are not valid
+	ErrInvalidPostProcessorArgs = errors.New("invalid post processor arguments")
+	//ErrInvalidTemplateName template name is not a valid folder name
+	ErrInvalidTemplateName = errors.New("invalid template name")
+	//ErrInvalidTemplate template files don't pass the validation
+	ErrInvalidTemplate = errors.New("invalid template")
+	//ErrInvalidVariables variables don't match the variables schema of the template
+	ErrInvalidVariables = errors.New("invalid variables")
+	//ErrInvalidSchema variables schema of the template can't be read
+	ErrInvalidSchema = errors.New("invalid variables schema")
+	//ErrInvalidOutput generated output doesn't match the output format
+	ErrInvalidOutput = errors.New("invalid output")
+	//ErrMultipleOutputs template declares multiple outputs and has to be generated as bundle
+	ErrMultipleOutputs = errors.New("template declares multiple outputs")
+	//ErrInvalidOutputs outputs of the template config are not valid
+	ErrInvalidOutputs = errors.New("invalid outputs")
+	//ErrInvalidExtends template extends a missing template or extends itself
+	ErrInvalidExtends = errors.New("invalid extends")
+	//ErrTemplateVersionNotFound no version of the template matches the version constraint
+	ErrTemplateVersionNotFound = errors.New("template version not found")
+	//ErrTemplateConflict template would replace or be created inside another template, e.g. an unversioned upload of a versioned template
+	ErrTemplateConflict = errors.New("template conflict")
+	//ErrInvalidVersionConstraint version constraint can't be parsed
+	ErrInvalidVersionConstraint = errors.New("invalid version constraint")
+	//ErrInvalidDefaults default variables of the template are not valid
+	ErrInvalidDefaults = errors.New("invalid default variables")
+	//ErrInvalidMissingKey missing_key of the template config is not valid
+	ErrInvalidMissingKey = errors.New("invalid missing_key")
+	//ErrMissingKey template accesses a variable which is not set
+	ErrMissingKey = errors.New("missing key")
+	//ErrInvalidDelimiters delimiters of the template config are not valid or don't match the template files
+	ErrInvalidDelimiters = errors.New("invalid delimiters")
+	//ErrFunctionNotAllowed template calls a function which is blocked for the template
+	ErrFunctionNotAllowed = errors.New("function not allowed")
+	//ErrInvalidLimits render_timeout or max_output_size of the template config are not valid
+	ErrInvalidLimits = errors.New("invalid render limits")
+	//ErrRenderTimeout template execution took longer than the render timeout
+	ErrRenderTimeout = errors.New("render timeout exceeded")
+	//ErrRenderCanceled template execution was canceled
+	ErrRenderCanceled = errors.New("render canceled")
+	//ErrOutputTooLarge generated output is larger than the max output size
+	ErrOutputTooLarge = errors.New("output too large")
+	//ErrInvalidFeatures features.yaml can't be read or contains invalid constraints
+	ErrInvalidFeatures = errors.New("invalid features")
+	//ErrFeatureNotFound template checks a feature which is not declared in a features.yaml
+	ErrFeatureNotFound = errors.New("feature not found")
+	//ErrInvalidFeatureVersion version to check the features against is not a semantic version
+	ErrInvalidFeatureVersion = errors.New("invalid feature version")
+	//ErrInvalidData data file of the data folder can't be read
+	ErrInvalidData = errors.New("invalid data")
+	//ErrDataNotFound template looks up a data file which doesn't exist
+	ErrDataNotFound = errors.New("data not found")
+	//ErrRender template can't be parsed or executed
+	ErrRender = errors.New("render error")
+	//ErrNotSupported template engine doesn't support the operation
+	ErrNotSupported = errors.New("not supported by the template engine")
+	//ErrReadOnlyStore template store doesn't allow to change templates
+	ErrReadOnlyStore = errors.New("template store is read only")
 )
 
 // Engine enum
@@ -35,26 +93,253 @@ const (
 	EngineGolang = "golang"
 )
 
+// MissingKeyMode is the behaviour if a template accesses a variable which is not set.
+type MissingKeyMode string
+
+const (
+	// MissingKeyModeDefault renders "<no value>" for missing variables
+	MissingKeyModeDefault MissingKeyMode = "default"
+	// MissingKeyModeZero renders the zero value for missing variables
+	MissingKeyModeZero MissingKeyMode = "zero"
+	// MissingKeyModeError stops the generation with a MissingKeyError
+	MissingKeyModeError MissingKeyMode = "error"
+)
+
 // TemplateConfig is the model of template config.
 // So each template we want to use have to have this config.
 // This config lives in the main_pattern folder under the name *config.json*.
 type TemplateConfig struct {
 	// TemplateEngine
-	TemplateEngine Engine `yaml:"engine" enums:"golang"`
+	TemplateEngine Engine `yaml:"engine" json:"engine" enums:"golang"`
+	// EngineOptions are engine specific options, which are passed to the engine factory.
+	EngineOptions map[string]interface{} `yaml:"engine_options" json:"engine_options,omitempty"`
 	// MainPattern the name of the templates which are loaded for that generation.
 	// (e.g. "*.goyaml") this will be replaced by <basefolder>/<template>/<main_pattern>.
-	MainPattern string `yaml:"main_pattern"`
+	MainPattern string `yaml:"main_pattern" json:"main_pattern"`
 	// IncludePattern the name of the templates which are loaded for that generation.
 	// These files are mainly for inclusion and can be shared between multiple templates.
 	// (e.g. "includes/*.goyaml") this will be replaced by <basefolder>/<include_pattern>.
-	IncludePattern string `yaml:"include_pattern"`
+	IncludePattern string `yaml:"include_pattern" json:"include_pattern,omitempty"`
 	// MainTemplate name of the template file that is used as entry point for the generation.
 	// (e.g. "main.goyaml")
-	MainTemplate string `yaml:"main_template"`
+	MainTemplate string `yaml:"main_template" json:"main_template"`
 	// PostProcessors are used to manipulate the generated code after generation.
-	PostProcessors []string `yaml:"post_processors"`
+	PostProcessors []PostProcessorConfig `yaml:"post_processors" json:"post_processors,omitempty"`
 	// Format gives the output format of the template.
 	// e.g.: json, json5, text (Default is text)
 	// This information is also used to find the correct response Content-Type for the sync restcall.
-	OutputFormat string `yaml:"output_format"`
+	OutputFormat string `yaml:"output_format" json:"output_format,omitempty"`
+	// Outputs declares multiple files, which are generated together as bundle.
+	// Each output has its own main template, format and post processors.
+	// Unset formats and post processors are taken from the template config.
+	Outputs []OutputConfig `yaml:"outputs" json:"outputs,omitempty"`
+	// ValidateOutput enables the strict mode, where json, json5 and yaml outputs are parsed after post processing.
+	// Outputs which can't be parsed are reported as error instead of being returned.
+	ValidateOutput bool `yaml:"validate_output" json:"validate_output,omitempty"`
+	// VariablesSchema is the name of a JSON schema file in the template folder.
+	// If set, the variables of each generation request are validated against this schema before rendering.
+	// (e.g. "schema.json") this will be replaced by <basefolder>/<template>/<variables_schema>.
+	VariablesSchema string `yaml:"variables_schema" json:"variables_schema,omitempty"`
+	// Defaults are default variables, the request variables are deep merged over them.
+	// The defaults.yaml of the template folder is merged under these defaults.
+	Defaults map[string]interface{} `yaml:"defaults" json:"defaults,omitempty"`
+	// ListMerge is the policy to merge lists of the request variables with lists of the defaults.
+	// e.g.: replace, append (Default is replace)
+	ListMerge ListMerge `yaml:"list_merge" json:"list_merge,omitempty" enums:"replace,append"`
+	// MissingKey controls the behaviour if a template accesses a variable which is not set.
+	// e.g.: default, zero, error (Default is default, which renders "<no value>")
+	MissingKey MissingKeyMode `yaml:"missing_key" json:"missing_key,omitempty" enums:"default,zero,error"`
+	// Delimiters replace the default action delimiters "{{" and "}}" of the main and include templates.
+	Delimiters *Delimiters `yaml:"delimiters" json:"delimiters,omitempty"`
+	// Functions restricts the functions which can be called by the templates.
+	Functions *FunctionsConfig `yaml:"functions" json:"functions,omitempty"`
+	// RenderTimeout limits the time of a single template execution (e.g. "5s").
+	// The stricter of this timeout and the timeout of the server is used.
+	RenderTimeout string `yaml:"render_timeout" json:"render_timeout,omitempty"`
+	// MaxOutputSize limits the size of a single generated output in bytes.
+	// The stricter of this size and the size of the server is used.
+	MaxOutputSize int64 `yaml:"max_output_size" json:"max_output_size,omitempty"`
+	// VersionVariable is the dot separated path of the variable with the software version (e.g. "image.image_version").
+	// The feature function checks the features of the features.yaml against this version.
+	VersionVariable string `yaml:"version_variable" json:"version_variable,omitempty"`
+	// PlatformVariable is the dot separated path of the variable with the platform (e.g. "device.platform").
+	// If set, the feature function uses the constraints of this platform.
+	PlatformVariable string `yaml:"platform_variable" json:"platform_variable,omitempty"`
+	// Extends is the name of a parent template.
+	// Unset attributes are taken from the parent and the templates of the parent are parsed before
+	// the templates of this template, so that this template can redefine single define blocks.
+	Extends string `yaml:"extends" json:"extends,omitempty"`
+	// parents are the template folders this template extends, the direct parent first.
+	parents []string
+	// inheritedPatterns are the resolved patterns of the parent templates, the top most parent first.
+	inheritedPatterns []string
+	// sandbox is set if the repository runs in sandbox mode, which blocks the functions giving access to the server.
+	sandbox bool
+	// features are the features of the features.yaml of the template path, the parents and the template folder.
+	features Features
+	// data are the data files of the data folders of the template path, the parents and the template folder.
+	// It is read when the template is loaded.
+	data map[string]interface{}
+	// store is the template store of the repository, see Store.
+	store TemplateStore
+}
+
+// FunctionsConfig is an allow and deny list of template functions.
+// The builtin functions of the engine (e.g. and, or, index, printf) are always allowed.
+type FunctionsConfig struct {
+	// Allow lists the allowed functions, if set all other functions are blocked.
+	Allow []string `yaml:"allow" json:"allow,omitempty"`
+	// Deny lists the blocked functions.
+	Deny []string `yaml:"deny" json:"deny,omitempty"`
+}
+
+// Store returns the template store of the repository, the engines read the files matching the patterns from it.
+// The patterns are relative to the store.
+func (c *TemplateConfig) Store() TemplateStore {
+	return c.store
+}
+
+// Patterns returns all patterns of the template in parse order without duplicates.
+// Templates of later patterns override the templates of earlier patterns with the same name.
+func (c *TemplateConfig) Patterns() []string {
+	patterns := make([]string, 0, len(c.inheritedPatterns)+2)
+	for _, pattern := range append(append([]string{}, c.inheritedPatterns...), c.MainPattern, c.IncludePattern) {
+		if len(pattern) > 0 && !containsString(patterns, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// inherit takes all unset attributes from the parent template config.
+func (c *TemplateConfig) inherit(parent *TemplateConfig) {
+	if len(c.TemplateEngine) == 0 {
+		c.TemplateEngine = parent.TemplateEngine
+	}
+	if c.EngineOptions == nil {
+		c.EngineOptions = parent.EngineOptions
+	}
+	if len(c.MainTemplate) == 0 {
+		c.MainTemplate = parent.MainTemplate
+	}
+	if c.PostProcessors == nil {
+		c.PostProcessors = parent.PostProcessors
+	}
+	if len(c.OutputFormat) == 0 {
+		c.OutputFormat = parent.OutputFormat
+	}
+	if c.Outputs == nil {
+		c.Outputs = parent.Outputs
+	}
+	if len(c.VariablesSchema) == 0 {
+		c.VariablesSchema = parent.VariablesSchema
+	}
+	if len(c.ListMerge) == 0 {
+		c.ListMerge = parent.ListMerge
+	}
+	if len(c.MissingKey) == 0 {
+		c.MissingKey = parent.MissingKey
+	}
+	if c.Delimiters == nil {
+		c.Delimiters = parent.Delimiters
+	}
+	if c.Functions == nil {
+		c.Functions = parent.Functions
+	}
+	if len(c.RenderTimeout) == 0 {
+		c.RenderTimeout = parent.RenderTimeout
+	}
+	if c.MaxOutputSize == 0 {
+		c.MaxOutputSize = parent.MaxOutputSize
+	}
+	if len(c.VersionVariable) == 0 {
+		c.VersionVariable = parent.VersionVariable
+	}
+	if len(c.PlatformVariable) == 0 {
+		c.PlatformVariable = parent.PlatformVariable
+	}
+	c.features = mergeFeatures(parent.features, c.features)
+	if len(parent.Defaults) > 0 {
+		c.Defaults = mergeVariables(parent.Defaults, c.Defaults, c.ListMerge)
+	}
+	c.ValidateOutput = c.ValidateOutput || parent.ValidateOutput
+	c.inheritedPatterns = append([]string{}, parent.inheritedPatterns...)
+	for _, pattern := range []string{parent.MainPattern, parent.IncludePattern} {
+		if len(pattern) > 0 {
+			c.inheritedPatterns = append(c.inheritedPatterns, pattern)
+		}
+	}
+}
+
+// Delimiters are the action delimiters of a template.
+type Delimiters struct {
+	// Left delimiter (e.g. "[[")
+	Left string `yaml:"left" json:"left"`
+	// Right delimiter (e.g. "]]")
+	Right string `yaml:"right" json:"right"`
+}
+
+// defaultDelimiters are the delimiters of templates without configured delimiters.
+var defaultDelimiters = Delimiters{Left: "{{", Right: "}}"}
+
+// delimiters returns the delimiters of the template, the default delimiters if none are configured.
+func (c *TemplateConfig) delimiters() Delimiters {
+	if c.Delimiters == nil {
+		return defaultDelimiters
+	}
+	return *c.Delimiters
+}
+
+// OutputConfig declares a file of a multi file template.
+type OutputConfig struct {
+	// Name of the generated file (e.g. "acl.txt")
+	Name string `yaml:"name" json:"name"`
+	// MainTemplate name of the template file that is used as entry point for this output.
+	MainTemplate string `yaml:"main_template" json:"main_template"`
+	// OutputFormat gives the output format of this output.
+	OutputFormat string `yaml:"output_format" json:"output_format,omitempty"`
+	// PostProcessors are used to manipulate this output after generation.
+	PostProcessors []PostProcessorConfig `yaml:"post_processors" json:"post_processors,omitempty"`
+}
+
+// resolvedOutputs returns the outputs of the template.
+// Templates without declared outputs have exactly one output, which is described by the template config itself.
+func (c *TemplateConfig) resolvedOutputs() []OutputConfig {
+	if len(c.Outputs) == 0 {
+		return []OutputConfig{{
+			Name:           c.MainTemplate,
+			MainTemplate:   c.MainTemplate,
+			OutputFormat:   c.OutputFormat,
+			PostProcessors: c.PostProcessors,
+		}}
+	}
+	outputs := make([]OutputConfig, 0, len(c.Outputs))
+	for _, output := range c.Outputs {
+		if len(output.OutputFormat) == 0 {
+			output.OutputFormat = c.OutputFormat
+		}
+		if output.PostProcessors == nil {
+			output.PostProcessors = c.PostProcessors
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs
+}
+
+// TemplateInfo describes a template of the template folder.
+type TemplateInfo struct {
+	// Name of the template, this is the folder name relative to the template path.
+	Name string `json:"name"`
+	// Version of the template, if the template is stored as <name>/<semver>.
+	Version string `json:"version,omitempty"`
+	// Config is the template config as written in the config.yaml.
+	Config *TemplateConfig `json:"config,omitempty"`
+	// MainFiles are the files matched by the main pattern, relative to the template path.
+	MainFiles []string `json:"main_files,omitempty"`
+	// IncludeFiles are the files matched by the include pattern, relative to the template path.
+	IncludeFiles []string `json:"include_files,omitempty"`
+	// InheritedFiles are the files of the extended templates, relative to the template path.
+	InheritedFiles []string `json:"inherited_files,omitempty"`
+	// Error is set if the template config can't be read.
+	Error string `json:"error,omitempty"`
 }
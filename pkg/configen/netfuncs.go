@@ -0,0 +1,350 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
+)
+
+// maxSplitSubnets limits the number of subnets returned by cidrSplit.
+const maxSplitSubnets = 4096
+
+// netFuncMap returns the IP address and prefix functions of the templates.
+func netFuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"cidrAddress":       cidrAddress,
+		"cidrNetwork":       cidrNetwork,
+		"cidrPrefixLength":  cidrPrefixLength,
+		"cidrNetmask":       cidrNetmask,
+		"cidrWildcard":      cidrWildcard,
+		"cidrHost":          cidrHost,
+		"cidrSubnet":        cidrSubnet,
+		"cidrSplit":         cidrSplit,
+		"cidrContains":      cidrContains,
+		"netmaskToPrefix":   netmaskToPrefix,
+		"prefixToNetmask":   prefixToNetmask,
+		"netmaskToWildcard": netmaskToWildcard,
+		"ipAdd":             ipAdd,
+		"eui64":             eui64,
+	}
+}
+
+// cidrAddress returns the address of a CIDR, e.g. 10.0.0.5/24 -> 10.0.0.5
+func cidrAddress(cidr string) (string, error) {
+	ip, _, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return ip.String(), nil
+}
+
+// cidrNetwork returns the network of a CIDR, e.g. 10.0.0.5/24 -> 10.0.0.0/24
+func cidrNetwork(cidr string) (string, error) {
+	_, network, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return network.String(), nil
+}
+
+// cidrPrefixLength returns the prefix length of a CIDR, e.g. 10.0.0.5/24 -> 24
+func cidrPrefixLength(cidr string) (int, error) {
+	_, network, err := parseCIDR(cidr)
+	if err != nil {
+		return 0, err
+	}
+	ones, _ := network.Mask.Size()
+	return ones, nil
+}
+
+// cidrNetmask returns the netmask of a CIDR, e.g. 10.0.0.5/24 -> 255.255.255.0
+func cidrNetmask(cidr string) (string, error) {
+	_, network, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return net.IP(network.Mask).String(), nil
+}
+
+// cidrWildcard returns the wildcard mask of a CIDR, e.g. 10.0.0.5/24 -> 0.0.0.255
+func cidrWildcard(cidr string) (string, error) {
+	_, network, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return net.IP(invertMask(network.Mask)).String(), nil
+}
+
+// cidrHost returns the nth address of the network of a CIDR, negative numbers count from the end.
+// e.g. 10.0.0.0/24 5 -> 10.0.0.5, 10.0.0.0/24 -2 -> 10.0.0.254
+func cidrHost(cidr string, n interface{}) (string, error) {
+	_, network, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	num, err := toInt64(n)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	offset := big.NewInt(num)
+	if num < 0 {
+		offset.Add(size, offset)
+	}
+	if offset.Sign() < 0 || offset.Cmp(size) >= 0 {
+		return "", fmt.Errorf("host number %d is out of range of %s", num, network)
+	}
+	return bigToIP(offset.Add(offset, ipToBig(network.IP)), bits).String(), nil
+}
+
+// cidrSubnet returns the subnet with the number of the CIDR extended by the new bits.
+// e.g. 10.0.0.0/16 8 2 -> 10.0.2.0/24
+func cidrSubnet(cidr string, newBits interface{}, num interface{}) (string, error) {
+	_, network, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	extension, err := toInt64(newBits)
+	if err != nil {
+		return "", err
+	}
+	subnet, err := toInt64(num)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := network.Mask.Size()
+	length := ones + int(extension)
+	if extension < 0 || length > bits {
+		return "", fmt.Errorf("can't extend %s by %d bits", network, extension)
+	}
+	if subnet < 0 || big.NewInt(subnet).Cmp(new(big.Int).Lsh(big.NewInt(1), uint(extension))) >= 0 {
+		return "", fmt.Errorf("subnet number %d is out of range of %s extended by %d bits", subnet, network, extension)
+	}
+	base := new(big.Int).Lsh(big.NewInt(subnet), uint(bits-length))
+	ip := bigToIP(base.Add(base, ipToBig(network.IP)), bits)
+	return (&net.IPNet{IP: ip, Mask: net.CIDRMask(length, bits)}).String(), nil
+}
+
+// cidrSplit splits the network of a CIDR into all subnets with the prefix length.
+// e.g. 10.0.0.0/23 24 -> [10.0.0.0/24 10.0.1.0/24]
+func cidrSplit(cidr string, prefixLength interface{}) ([]string, error) {
+	_, network, err := parseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	length, err := toInt64(prefixLength)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := network.Mask.Size()
+	if int(length) < ones || int(length) > bits {
+		return nil, fmt.Errorf("can't split %s into /%d subnets", network, length)
+	}
+	// The count is only shifted for small differences, it overflows for long IPv6 prefixes.
+	newBits := length - int64(ones)
+	if newBits >= 62 || int64(1)<<newBits > maxSplitSubnets {
+		return nil, fmt.Errorf("splitting %s into /%d subnets results in more than %d subnets", network, length, maxSplitSubnets)
+	}
+	subnets := make([]string, 0, int64(1)<<newBits)
+	for i := int64(0); i < int64(1)<<newBits; i++ {
+		subnet, err := cidrSubnet(network.String(), newBits, i)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+// cidrContains checks whether the network of a CIDR contains an address or a whole prefix.
+// e.g. 10.0.0.0/8 10.1.2.3 -> true, 10.0.0.0/8 10.1.0.0/16 -> true
+func cidrContains(cidr string, addressOrCIDR string) (bool, error) {
+	_, network, err := parseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+	if ip := net.ParseIP(addressOrCIDR); ip != nil {
+		return network.Contains(ip), nil
+	}
+	_, other, err := parseCIDR(addressOrCIDR)
+	if err != nil {
+		return false, err
+	}
+	ones, bits := network.Mask.Size()
+	otherOnes, otherBits := other.Mask.Size()
+	return bits == otherBits && otherOnes >= ones && network.Contains(other.IP), nil
+}
+
+// netmaskToPrefix returns the prefix length of a netmask, e.g. 255.255.255.0 -> 24
+func netmaskToPrefix(netmask string) (int, error) {
+	mask, err := parseNetmask(netmask)
+	if err != nil {
+		return 0, err
+	}
+	ones, _ := mask.Size()
+	return ones, nil
+}
+
+// prefixToNetmask returns the IPv4 netmask of a prefix length, e.g. 24 -> 255.255.255.0
+func prefixToNetmask(prefixLength interface{}) (string, error) {
+	length, err := toInt64(prefixLength)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 || length > 32 {
+		return "", fmt.Errorf("invalid IPv4 prefix length %d", length)
+	}
+	return net.IP(net.CIDRMask(int(length), 32)).String(), nil
+}
+
+// netmaskToWildcard returns the wildcard mask of a netmask, e.g. 255.255.255.0 -> 0.0.0.255
+func netmaskToWildcard(netmask string) (string, error) {
+	mask, err := parseNetmask(netmask)
+	if err != nil {
+		return "", err
+	}
+	return net.IP(invertMask(mask)).String(), nil
+}
+
+// ipAdd adds a number to an IPv4 or IPv6 address, e.g. 10.0.0.1 5 -> 10.0.0.6
+func ipAdd(address string, n interface{}) (string, error) {
+	ip, bits, err := parseIP(address)
+	if err != nil {
+		return "", err
+	}
+	num, err := toInt64(n)
+	if err != nil {
+		return "", err
+	}
+	result := new(big.Int).Add(ipToBig(ip), big.NewInt(num))
+	if result.Sign() < 0 || result.BitLen() > bits {
+		return "", fmt.Errorf("%s plus %d is out of the address range", address, num)
+	}
+	return bigToIP(result, bits).String(), nil
+}
+
+// eui64 returns the IPv6 address of the /64 prefix with the modified EUI-64 interface identifier of the MAC address.
+// e.g. 2001:db8::/64 00:11:22:33:44:55 -> 2001:db8::211:22ff:fe33:4455
+func eui64(cidr string, mac string) (string, error) {
+	_, network, err := parseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := network.Mask.Size()
+	if bits != 128 || ones > 64 {
+		return "", fmt.Errorf("%s is not an IPv6 prefix of at most 64 bits", network)
+	}
+	hardwareAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return "", err
+	}
+	if len(hardwareAddr) != 6 {
+		return "", fmt.Errorf("%s is not a 48-bit MAC address", mac)
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, network.IP.To16()[:8])
+	ip[8] = hardwareAddr[0] ^ 0x02
+	ip[9] = hardwareAddr[1]
+	ip[10] = hardwareAddr[2]
+	ip[11] = 0xff
+	ip[12] = 0xfe
+	ip[13] = hardwareAddr[3]
+	ip[14] = hardwareAddr[4]
+	ip[15] = hardwareAddr[5]
+	return ip.String(), nil
+}
+
+// parseCIDR parses a CIDR and returns the IPv4 addresses in their 4 byte form.
+func parseCIDR(cidr string) (net.IP, *net.IPNet, error) {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return ip, network, nil
+}
+
+// parseIP parses an IPv4 or IPv6 address and returns the address together with its number of bits.
+func parseIP(address string) (net.IP, int, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, 0, fmt.Errorf("invalid IP address %q", address)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4, 32, nil
+	}
+	return ip, 128, nil
+}
+
+// parseNetmask parses a netmask in address notation, e.g. 255.255.255.0
+func parseNetmask(netmask string) (net.IPMask, error) {
+	ip, _, err := parseIP(netmask)
+	if err != nil {
+		return nil, err
+	}
+	mask := net.IPMask(ip)
+	if _, bits := mask.Size(); bits == 0 {
+		return nil, fmt.Errorf("netmask %s is not contiguous", netmask)
+	}
+	return mask, nil
+}
+
+func invertMask(mask net.IPMask) net.IPMask {
+	inverted := make(net.IPMask, len(mask))
+	for i, b := range mask {
+		inverted[i] = ^b
+	}
+	return inverted
+}
+
+func ipToBig(ip net.IP) *big.Int {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return new(big.Int).SetBytes(ip)
+}
+
+func bigToIP(n *big.Int, bits int) net.IP {
+	ip := make(net.IP, bits/8)
+	b := n.Bytes()
+	copy(ip[len(ip)-len(b):], b)
+	return ip
+}
+
+// toInt64 converts the numbers of templates and of the JSON variables.
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("invalid number type: %v", reflect.TypeOf(value))
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCidrFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		function func(string) (string, error)
+		cidr     string
+		want     string
+		wantErr  bool
+	}{
+		{name: "address", function: cidrAddress, cidr: "10.0.0.5/24", want: "10.0.0.5"},
+		{name: "address ipv6", function: cidrAddress, cidr: "2001:db8::5/64", want: "2001:db8::5"},
+		{name: "network", function: cidrNetwork, cidr: "10.0.0.5/24", want: "10.0.0.0/24"},
+		{name: "network ipv6", function: cidrNetwork, cidr: "2001:db8:0:1::5/48", want: "2001:db8::/48"},
+		{name: "netmask", function: cidrNetmask, cidr: "10.0.0.5/20", want: "255.255.240.0"},
+		{name: "wildcard", function: cidrWildcard, cidr: "10.0.0.5/20", want: "0.0.15.255"},
+		{name: "invalid cidr", function: cidrNetwork, cidr: "10.0.0.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.function(tt.cidr)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_cidrPrefixLength(t *testing.T) {
+	got, err := cidrPrefixLength("2001:db8::1/56")
+	require.NoError(t, err)
+	require.Equal(t, 56, got)
+}
+
+func Test_cidrHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr    string
+		n       interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "first host", cidr: "10.0.0.0/24", n: 1, want: "10.0.0.1"},
+		{name: "host of address", cidr: "10.0.0.77/24", n: 5, want: "10.0.0.5"},
+		{name: "json number", cidr: "10.0.0.0/24", n: 10.0, want: "10.0.0.10"},
+		{name: "from end", cidr: "10.0.0.0/24", n: -2, want: "10.0.0.254"},
+		{name: "ipv6", cidr: "2001:db8::/64", n: 256, want: "2001:db8::100"},
+		{name: "out of range", cidr: "10.0.0.0/30", n: 4, wantErr: true},
+		{name: "out of range from end", cidr: "10.0.0.0/30", n: -5, wantErr: true},
+		{name: "no integer", cidr: "10.0.0.0/24", n: 1.5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cidrHost(tt.cidr, tt.n)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_cidrSubnet(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr    string
+		newBits interface{}
+		num     interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "ipv4", cidr: "10.0.0.0/16", newBits: 8, num: 2, want: "10.0.2.0/24"},
+		{name: "ipv6", cidr: "2001:db8::/48", newBits: 16, num: 255, want: "2001:db8:0:ff::/64"},
+		{name: "same size", cidr: "10.0.0.0/16", newBits: 0, num: 0, want: "10.0.0.0/16"},
+		{name: "number out of range", cidr: "10.0.0.0/16", newBits: 2, num: 4, wantErr: true},
+		{name: "too many bits", cidr: "10.0.0.0/16", newBits: 17, num: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cidrSubnet(tt.cidr, tt.newBits, tt.num)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_cidrSplit(t *testing.T) {
+	tests := []struct {
+		name         string
+		cidr         string
+		prefixLength interface{}
+		want         []string
+		wantErr      bool
+	}{
+		{name: "ipv4", cidr: "10.0.0.0/23", prefixLength: 25, want: []string{"10.0.0.0/25", "10.0.0.128/25", "10.0.1.0/25", "10.0.1.128/25"}},
+		{name: "ipv6", cidr: "2001:db8::/63", prefixLength: 64, want: []string{"2001:db8::/64", "2001:db8:0:1::/64"}},
+		{name: "shorter prefix", cidr: "10.0.0.0/23", prefixLength: 22, wantErr: true},
+		{name: "too many subnets", cidr: "10.0.0.0/8", prefixLength: 24, wantErr: true},
+		{name: "one more than the limit", cidr: "10.0.0.0/19", prefixLength: 32, wantErr: true},
+		{name: "too many ipv6 subnets", cidr: "2001:db8::/32", prefixLength: 128, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cidrSplit(tt.cidr, tt.prefixLength)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_cidrContains(t *testing.T) {
+	tests := []struct {
+		name          string
+		cidr          string
+		addressOrCIDR string
+		want          bool
+		wantErr       bool
+	}{
+		{name: "address inside", cidr: "10.0.0.0/8", addressOrCIDR: "10.1.2.3", want: true},
+		{name: "address outside", cidr: "10.0.0.0/8", addressOrCIDR: "11.1.2.3", want: false},
+		{name: "prefix inside", cidr: "10.0.0.0/8", addressOrCIDR: "10.1.0.0/16", want: true},
+		{name: "larger prefix", cidr: "10.1.0.0/16", addressOrCIDR: "10.0.0.0/8", want: false},
+		{name: "ipv6 address", cidr: "2001:db8::/32", addressOrCIDR: "2001:db8:1::1", want: true},
+		{name: "other family", cidr: "::/0", addressOrCIDR: "10.0.0.0/8", want: false},
+		{name: "invalid", cidr: "10.0.0.0/8", addressOrCIDR: "host", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cidrContains(tt.cidr, tt.addressOrCIDR)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNetmaskFunctions(t *testing.T) {
+	is := require.New(t)
+	prefix, err := netmaskToPrefix("255.255.255.192")
+	is.NoError(err)
+	is.Equal(26, prefix)
+	_, err = netmaskToPrefix("255.0.255.0")
+	is.Error(err)
+
+	netmask, err := prefixToNetmask(26)
+	is.NoError(err)
+	is.Equal("255.255.255.192", netmask)
+	_, err = prefixToNetmask(33)
+	is.Error(err)
+
+	wildcard, err := netmaskToWildcard("255.255.255.192")
+	is.NoError(err)
+	is.Equal("0.0.0.63", wildcard)
+}
+
+func Test_ipAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		n       interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "ipv4", address: "10.0.0.1", n: 5, want: "10.0.0.6"},
+		{name: "ipv4 carry", address: "10.0.0.255", n: 1, want: "10.0.1.0"},
+		{name: "ipv4 decrement", address: "10.0.1.0", n: -1, want: "10.0.0.255"},
+		{name: "ipv6", address: "2001:db8::ffff", n: 1, want: "2001:db8::1:0"},
+		{name: "ipv4 overflow", address: "255.255.255.255", n: 1, wantErr: true},
+		{name: "ipv4 underflow", address: "0.0.0.0", n: -1, wantErr: true},
+		{name: "invalid address", address: "10.0.0", n: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ipAdd(tt.address, tt.n)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_eui64(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr    string
+		mac     string
+		want    string
+		wantErr bool
+	}{
+		{name: "eui64", cidr: "2001:db8::/64", mac: "00:11:22:33:44:55", want: "2001:db8::211:22ff:fe33:4455"},
+		{name: "local bit set", cidr: "fe80::/64", mac: "02:00:5e:10:00:01", want: "fe80::5eff:fe10:1"},
+		{name: "ipv4 prefix", cidr: "10.0.0.0/8", mac: "00:11:22:33:44:55", wantErr: true},
+		{name: "long prefix", cidr: "2001:db8::/96", mac: "00:11:22:33:44:55", wantErr: true},
+		{name: "invalid mac", cidr: "2001:db8::/64", mac: "00:11:22", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := eui64(tt.cidr, tt.mac)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNetFunctionsInTemplates(t *testing.T) {
+	is := require.New(t)
+	tpl, err := template.New("t").Funcs(goFuncMap()).Parse(`{{cidrHost .prefix 1}} {{cidrNetmask .prefix}} {{range cidrSplit .prefix 25}}{{.}} {{end}}`)
+	is.NoError(err)
+	var got bytes.Buffer
+	is.NoError(tpl.Execute(&got, map[string]interface{}{"prefix": "192.168.0.0/24"}))
+	is.Equal("192.168.0.1 255.255.255.0 192.168.0.0/25 192.168.0.128/25 ", got.String())
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import "time"
+
+// Option configures a repository.
+type Option func(r *Repository)
+
+// WithSandbox enables the sandbox mode, which blocks all functions giving access to the server
+// (e.g. env and expandenv) in all templates.
+func WithSandbox(sandbox bool) Option {
+	return func(r *Repository) {
+		r.sandbox = sandbox
+	}
+}
+
+// WithRenderTimeout limits the time of a single template execution, 0 disables the limit.
+// Templates can configure a shorter render_timeout in their config.yaml.
+func WithRenderTimeout(timeout time.Duration) Option {
+	return func(r *Repository) {
+		r.renderTimeout = timeout
+	}
+}
+
+// WithMaxOutputSize limits the size of a single generated output in bytes, 0 disables the limit.
+// Templates can configure a smaller max_output_size in their config.yaml.
+func WithMaxOutputSize(maxOutputSize int64) Option {
+	return func(r *Repository) {
+		r.maxOutputSize = maxOutputSize
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yosuke-furukawa/json5/encoding/json5"
+	"gopkg.in/yaml.v3"
+)
+
+// snippetContextLines is the number of lines shown before and after the offending line.
+const snippetContextLines = 2
+
+var yamlErrorLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// OutputError is returned if the generated output can't be parsed in the declared output format.
+type OutputError struct {
+	// Format is the declared output format
+	Format string
+	// Line of the error, starting with 1. 0 if the line is unknown.
+	Line int
+	// Column of the error, starting with 1. 0 if the column is unknown.
+	Column int
+	// Snippet shows the offending region of the output
+	Snippet string
+	// Err is the error of the parser
+	Err error
+}
+
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("%v: %s output at line %d, column %d: %v", ErrInvalidOutput, e.Format, e.Line, e.Column, e.Err)
+}
+
+// Unwrap allows to check the error with errors.Is(err, ErrInvalidOutput).
+func (e *OutputError) Unwrap() error {
+	return ErrInvalidOutput
+}
+
+// validateOutput parses the output in the given format.
+// Only json, json5 and yaml outputs are validated, all other formats are accepted as they are.
+func validateOutput(format string, output []byte) error {
+	var v interface{}
+	switch format {
+	case "json":
+		if err := json.Unmarshal(output, &v); err != nil {
+			if syntaxError, ok := err.(*json.SyntaxError); ok {
+				return newOutputErrorAtOffset(format, output, syntaxError.Offset, err)
+			}
+			return &OutputError{Format: format, Err: err}
+		}
+	case "json5":
+		if err := json5.Unmarshal(output, &v); err != nil {
+			if syntaxError, ok := err.(*json5.SyntaxError); ok {
+				return newOutputErrorAtOffset(format, output, syntaxError.Offset, err)
+			}
+			return &OutputError{Format: format, Err: err}
+		}
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(output, &v); err != nil {
+			outputError := &OutputError{Format: format, Err: err}
+			if match := yamlErrorLinePattern.FindStringSubmatch(err.Error()); match != nil {
+				outputError.Line, _ = strconv.Atoi(match[1])
+				outputError.Snippet = snippet(output, outputError.Line, 0)
+			}
+			return outputError
+		}
+	}
+	return nil
+}
+
+// newOutputErrorAtOffset creates an output error for an error after the offset bytes of the output.
+func newOutputErrorAtOffset(format string, output []byte, offset int64, err error) *OutputError {
+	if offset > int64(len(output)) {
+		offset = int64(len(output))
+	}
+	// The offset points behind the offending byte.
+	before := output[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n') - 1
+	if column < 1 {
+		column = 1
+	}
+	return &OutputError{
+		Format:  format,
+		Line:    line,
+		Column:  column,
+		Snippet: snippet(output, line, column),
+		Err:     err,
+	}
+}
+
+// snippet returns the lines around the given line with line numbers.
+// If the column is known, a marker points to the column.
+func snippet(output []byte, line int, column int) string {
+	lines := strings.Split(string(output), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	first := line - snippetContextLines
+	if first < 1 {
+		first = 1
+	}
+	last := line + snippetContextLines
+	if last > len(lines) {
+		last = len(lines)
+	}
+	width := len(strconv.Itoa(last))
+	var b strings.Builder
+	for i := first; i <= last; i++ {
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %*d | %s\n", marker, width, i, lines[i-1])
+		if i == line && column > 0 {
+			fmt.Fprintf(&b, "  %s | %s^\n", strings.Repeat(" ", width), strings.Repeat(" ", column-1))
+		}
+	}
+	return b.String()
+}
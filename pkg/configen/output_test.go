@@ -0,0 +1,95 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		output  string
+		line    int
+		column  int
+		snippet string
+		wantErr bool
+	}{
+		{
+			name:   "valid json",
+			format: "json",
+			output: `{"key": "value"}`,
+		}, {
+			name:    "missing comma in json",
+			format:  "json",
+			output:  "{\n  \"key1\": \"v1\"\n  \"key2\": \"v2\"\n}",
+			line:    3,
+			column:  3,
+			snippet: "  1 | {\n  2 |   \"key1\": \"v1\"\n> 3 |   \"key2\": \"v2\"\n    |   ^\n  4 | }\n",
+			wantErr: true,
+		}, {
+			name:   "trailing comma is valid json5",
+			format: "json5",
+			output: `{key: "value",}`,
+		}, {
+			name:    "invalid json5",
+			format:  "json5",
+			output:  `{key: "value" "other"}`,
+			line:    1,
+			column:  15,
+			snippet: "> 1 | {key: \"value\" \"other\"}\n    |               ^\n",
+			wantErr: true,
+		}, {
+			name:   "valid yaml",
+			format: "yaml",
+			output: "key: value\nlist:\n  - 1\n",
+		}, {
+			name:    "invalid yaml",
+			format:  "yaml",
+			output:  "key: value\nlist: - 1\n",
+			line:    2,
+			snippet: "  1 | key: value\n> 2 | list: - 1\n  3 | \n",
+			wantErr: true,
+		}, {
+			name:   "text is not validated",
+			format: "txt",
+			output: `{"key": `,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOutput(tt.format, []byte(tt.output))
+			if !tt.wantErr {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, errors.Is(err, ErrInvalidOutput))
+			var outputError *OutputError
+			require.True(t, errors.As(err, &outputError))
+			require.Equal(t, tt.line, outputError.Line)
+			require.Equal(t, tt.column, outputError.Column)
+			if diff := cmp.Diff(tt.snippet, outputError.Snippet); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
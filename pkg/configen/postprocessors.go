@@ -0,0 +1,268 @@
+/*
+ * Copyright 2020 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/pretty"
+	"gopkg.in/yaml.v3"
+)
+
+// maxIndent limits the indent argument of the JSON post processors.
+const maxIndent = 16
+
+// PostProcessor manipulates the generated output.
+type PostProcessor func(value []byte) ([]byte, error)
+
+// PostProcessorFactory creates a post processor.
+// The args are the arguments of the post processor in the config.yaml.
+// Invalid arguments have to be reported by the factory.
+type PostProcessorFactory func(args map[string]interface{}) (PostProcessor, error)
+
+// PostProcessorConfig selects a post processor in the config.yaml.
+// It is either written as bare name (e.g. prettyJSON) or as name with arguments
+// (e.g. {name: indentJSON, args: {indent: 4}}).
+type PostProcessorConfig struct {
+	// Name of the post processor
+	Name string `yaml:"name" json:"name"`
+	// Args are the arguments of the post processor
+	Args map[string]interface{} `yaml:"args" json:"args,omitempty"`
+}
+
+// UnmarshalYAML allows to specify post processors without arguments by their name.
+func (c *PostProcessorConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		c.Name = value.Value
+		return nil
+	}
+	type plain PostProcessorConfig
+	return value.Decode((*plain)(c))
+}
+
+var (
+	postProcessorsMutex sync.RWMutex
+	postProcessors      = map[string]PostProcessorFactory{
+		"removeTrailingCommas": withoutArgs(removeTrailingCommas),
+		"removeEmptyLines":     withoutArgs(removeEmptyLines),
+		"prettyJSON":           newPrettyJSON,
+		"uglyJSON":             withoutArgs(uglyJSON),
+		"indentJSON":           newIndentJSON,
+	}
+	removeTrailingCommasPattern = regexp.MustCompile(`(\s*),(\s*[}\]])`)
+	removeEmptyLinesPattern     = regexp.MustCompile(`\n(( )*\n)+`)
+)
+
+// RegisterPostProcessor makes a post processor available under the given name.
+// It panics if the name is empty, the factory is nil or a post processor with the same name is already registered.
+func RegisterPostProcessor(name string, factory PostProcessorFactory) {
+	postProcessorsMutex.Lock()
+	defer postProcessorsMutex.Unlock()
+	if name == "" {
+		panic("configen: RegisterPostProcessor name is empty")
+	}
+	if factory == nil {
+		panic("configen: RegisterPostProcessor factory is nil")
+	}
+	if _, ok := postProcessors[name]; ok {
+		panic(fmt.Sprintf("configen: RegisterPostProcessor called twice for post processor %s", name))
+	}
+	postProcessors[name] = factory
+}
+
+// PostProcessors returns the names of all registered post processors.
+func PostProcessors() []string {
+	postProcessorsMutex.RLock()
+	defer postProcessorsMutex.RUnlock()
+	names := make([]string, 0, len(postProcessors))
+	for name := range postProcessors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newPostProcessors creates the post processors of the template config in the configured order.
+func newPostProcessors(configs []PostProcessorConfig) ([]PostProcessor, error) {
+	processors := make([]PostProcessor, 0, len(configs))
+	for _, config := range configs {
+		postProcessorsMutex.RLock()
+		factory, ok := postProcessors[config.Name]
+		postProcessorsMutex.RUnlock()
+		if !ok {
+			return nil, errors.WithMessage(ErrPostProcessorNotFound, config.Name)
+		}
+		processor, err := factory(config.Args)
+		if err != nil {
+			return nil, errors.WithMessagef(ErrInvalidPostProcessorArgs, "%s: %v", config.Name, err)
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
+
+// withoutArgs creates a factory for post processors which don't have arguments.
+func withoutArgs(processor PostProcessor) PostProcessorFactory {
+	return func(args map[string]interface{}) (PostProcessor, error) {
+		if err := checkArgs(args); err != nil {
+			return nil, err
+		}
+		return processor, nil
+	}
+}
+
+// checkArgs reports arguments which are not known by the post processor.
+func checkArgs(args map[string]interface{}, known ...string) error {
+	unknown := make([]string, 0)
+	for name := range args {
+		if !containsString(known, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.Errorf("unknown arguments %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// intArg returns the integer argument or the default value if the argument is not set.
+func intArg(args map[string]interface{}, name string, defaultValue int) (int, error) {
+	value, ok := args[name]
+	if !ok {
+		return defaultValue, nil
+	}
+	number, ok := toFloat(value)
+	if !ok || number != float64(int(number)) {
+		return 0, errors.Errorf("argument %s has to be an integer", name)
+	}
+	return int(number), nil
+}
+
+// boolArg returns the boolean argument or the default value if the argument is not set.
+func boolArg(args map[string]interface{}, name string, defaultValue bool) (bool, error) {
+	value, ok := args[name]
+	if !ok {
+		return defaultValue, nil
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, errors.Errorf("argument %s has to be a boolean", name)
+	}
+	return b, nil
+}
+
+func removeTrailingCommas(value []byte) ([]byte, error) {
+	return []byte(removeTrailingCommasPattern.ReplaceAllString(string(value), "$1$2")), nil
+}
+
+func removeEmptyLines(value []byte) ([]byte, error) {
+	return []byte(removeEmptyLinesPattern.ReplaceAllString(string(value), "\n")), nil
+}
+
+func prettyJSON(value []byte) ([]byte, error) {
+	out := pretty.Pretty(value)
+	return out, nil
+}
+
+// newPrettyJSON creates the prettyJSON post processor.
+// Arguments: indent (number of spaces, default 2), width (max width of single line arrays, default 80),
+// sort_keys (default false).
+func newPrettyJSON(args map[string]interface{}) (PostProcessor, error) {
+	if len(args) == 0 {
+		return prettyJSON, nil
+	}
+	if err := checkArgs(args, "indent", "width", "sort_keys"); err != nil {
+		return nil, err
+	}
+	indent, err := intArg(args, "indent", 2)
+	if err != nil {
+		return nil, err
+	}
+	width, err := intArg(args, "width", pretty.DefaultOptions.Width)
+	if err != nil {
+		return nil, err
+	}
+	sortKeys, err := boolArg(args, "sort_keys", false)
+	if err != nil {
+		return nil, err
+	}
+	if indent < 0 || width < 0 {
+		return nil, errors.New("arguments indent and width must not be negative")
+	}
+	if indent > maxIndent {
+		return nil, errors.Errorf("argument indent must not exceed %d", maxIndent)
+	}
+	options := &pretty.Options{Indent: strings.Repeat(" ", indent), Width: width, SortKeys: sortKeys}
+	return func(value []byte) ([]byte, error) {
+		return pretty.PrettyOptions(value, options), nil
+	}, nil
+}
+
+func uglyJSON(value []byte) ([]byte, error) {
+	out := pretty.UglyInPlace(value)
+	return out, nil
+}
+
+// newIndentJSON creates the indentJSON post processor, which puts each JSON element on its own line.
+// Arguments: indent (number of spaces, default 2), tabs (indent with tabs instead of spaces, default false).
+func newIndentJSON(args map[string]interface{}) (PostProcessor, error) {
+	if err := checkArgs(args, "indent", "tabs"); err != nil {
+		return nil, err
+	}
+	indent, err := intArg(args, "indent", 2)
+	if err != nil {
+		return nil, err
+	}
+	if indent < 0 {
+		return nil, errors.New("argument indent must not be negative")
+	}
+	if indent > maxIndent {
+		return nil, errors.Errorf("argument indent must not exceed %d", maxIndent)
+	}
+	tabs, err := boolArg(args, "tabs", false)
+	if err != nil {
+		return nil, err
+	}
+	indentation := strings.Repeat(" ", indent)
+	if tabs {
+		indentation = "\t"
+	}
+	return func(value []byte) ([]byte, error) {
+		var out bytes.Buffer
+		if err := json.Indent(&out, value, "", indentation); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}, nil
+}
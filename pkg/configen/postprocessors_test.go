@@ -0,0 +1,125 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newPostProcessors(t *testing.T) {
+	tests := []struct {
+		name      string
+		configs   []PostProcessorConfig
+		input     []byte
+		want      []byte
+		wantedErr error
+	}{
+		{
+			name:    "indentJSON with default indent",
+			configs: []PostProcessorConfig{{Name: "indentJSON"}},
+			input:   []byte(`{"key1":"v1","list":[1,2]}`),
+			want:    []byte("{\n  \"key1\": \"v1\",\n  \"list\": [\n    1,\n    2\n  ]\n}"),
+		}, {
+			name:    "indentJSON with indent argument",
+			configs: []PostProcessorConfig{{Name: "indentJSON", Args: map[string]interface{}{"indent": 4}}},
+			input:   []byte(`{"key1":"v1"}`),
+			want:    []byte("{\n    \"key1\": \"v1\"\n}"),
+		}, {
+			name:    "indentJSON with tabs",
+			configs: []PostProcessorConfig{{Name: "indentJSON", Args: map[string]interface{}{"tabs": true}}},
+			input:   []byte(`{"key1":"v1"}`),
+			want:    []byte("{\n\t\"key1\": \"v1\"\n}"),
+		}, {
+			name:    "prettyJSON with sorted keys",
+			configs: []PostProcessorConfig{{Name: "prettyJSON", Args: map[string]interface{}{"sort_keys": true, "indent": 1}}},
+			input:   []byte(`{"key2":"v2","key1":"v1"}`),
+			want:    []byte("{\n \"key1\": \"v1\",\n \"key2\": \"v2\"\n}\n"),
+		}, {
+			name:    "post processors are applied in order",
+			configs: []PostProcessorConfig{{Name: "removeTrailingCommas"}, {Name: "uglyJSON"}},
+			input:   []byte("{\n  \"key1\": \"v1\",\n}"),
+			want:    []byte(`{"key1":"v1"}`),
+		}, {
+			name:      "unknown post processor",
+			configs:   []PostProcessorConfig{{Name: "unknown"}},
+			wantedErr: ErrPostProcessorNotFound,
+		}, {
+			name:      "unknown argument",
+			configs:   []PostProcessorConfig{{Name: "uglyJSON", Args: map[string]interface{}{"indent": 2}}},
+			wantedErr: ErrInvalidPostProcessorArgs,
+		}, {
+			name:      "invalid argument type",
+			configs:   []PostProcessorConfig{{Name: "indentJSON", Args: map[string]interface{}{"indent": "four"}}},
+			wantedErr: ErrInvalidPostProcessorArgs,
+		}, {
+			name:      "negative indent",
+			configs:   []PostProcessorConfig{{Name: "indentJSON", Args: map[string]interface{}{"indent": -1}}},
+			wantedErr: ErrInvalidPostProcessorArgs,
+		}, {
+			name:      "indent too large",
+			configs:   []PostProcessorConfig{{Name: "indentJSON", Args: map[string]interface{}{"indent": 1000000000}}},
+			wantedErr: ErrInvalidPostProcessorArgs,
+		}, {
+			name:      "prettyJSON indent too large",
+			configs:   []PostProcessorConfig{{Name: "prettyJSON", Args: map[string]interface{}{"indent": 17}}},
+			wantedErr: ErrInvalidPostProcessorArgs,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processors, err := newPostProcessors(tt.configs)
+			if tt.wantedErr != nil {
+				if !errors.Is(err, tt.wantedErr) {
+					t.Errorf("newPostProcessors() error = %v, wantedErr %v", err, tt.wantedErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			got := tt.input
+			for _, processor := range processors {
+				got, err = processor(got)
+				require.NoError(t, err)
+			}
+			if diff := cmp.Diff(string(tt.want), string(got)); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegisterPostProcessor(t *testing.T) {
+	is := require.New(t)
+	RegisterPostProcessor("upperCase", func(args map[string]interface{}) (PostProcessor, error) {
+		if err := checkArgs(args); err != nil {
+			return nil, err
+		}
+		return func(value []byte) ([]byte, error) { return bytes.ToUpper(value), nil }, nil
+	})
+	is.Contains(PostProcessors(), "upperCase")
+	is.Panics(func() { RegisterPostProcessor("upperCase", withoutArgs(uglyJSON)) })
+
+	processors, err := newPostProcessors([]PostProcessorConfig{{Name: "upperCase"}})
+	is.NoError(err)
+	got, err := processors[0]([]byte("abc"))
+	is.NoError(err)
+	is.Equal("ABC", string(got))
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+)
+
+// renderErrorPattern matches the errors of the go templates, e.g.
+// template: main.gotext:3:12: executing "main.gotext" at <.name>: ... or template: main.gotext:3: unexpected ...
+var renderErrorPattern = regexp.MustCompile(`(?s)^template: ([^:]+?)(?::(\d+))?(?::(\d+))?: (.*)$`)
+
+// RenderError is returned if a template can't be parsed or executed.
+type RenderError struct {
+	// Template is the template file of the error
+	Template string
+	// Line of the error, starting with 1. 0 if the line is unknown.
+	Line int
+	// Column of the error as reported by the template engine. 0 if the column is unknown.
+	Column int
+	// Action is the failing template action (e.g. "index .ports 3"), empty for parse errors.
+	Action string
+	// Message describes the error without its location
+	Message string
+	// Snippet shows the lines of the template file around the error
+	Snippet string
+	// Err is the error of the template engine
+	Err error
+}
+
+func (e *RenderError) Error() string {
+	if len(e.Action) > 0 {
+		return fmt.Sprintf("%v in template %s at line %d, column %d: <%s>: %s", ErrRender, e.Template, e.Line, e.Column, e.Action, e.Message)
+	}
+	return fmt.Sprintf("%v in template %s at line %d, column %d: %s", ErrRender, e.Template, e.Line, e.Column, e.Message)
+}
+
+// Unwrap allows to check the error with errors.Is(err, ErrRender).
+func (e *RenderError) Unwrap() error {
+	return ErrRender
+}
+
+// newRenderError converts a parse or execution error of the go templates, it returns nil for all other errors.
+// The files map the template file names to their paths in the template store, they are used to show the snippet of the error.
+func newRenderError(err error, store TemplateStore, files map[string]string) *RenderError {
+	match := renderErrorPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return nil
+	}
+	renderError := &RenderError{Template: match[1], Message: match[4], Err: err}
+	renderError.Line, _ = strconv.Atoi(match[2])
+	renderError.Column, _ = strconv.Atoi(match[3])
+	if field := execErrorFieldPattern.FindStringSubmatchIndex(renderError.Message); field != nil {
+		renderError.Action = renderError.Message[field[2]:field[3]]
+		renderError.Message = renderError.Message[field[1]:]
+	}
+	renderError.Snippet = fileSnippet(store, files[renderError.Template], renderError.Line, renderError.Column)
+	return renderError
+}
+
+// fileSnippet returns the lines of the file around the given line, an empty string if the file can't be read.
+func fileSnippet(store TemplateStore, file string, line int, column int) string {
+	if store == nil || len(file) == 0 || line < 1 {
+		return ""
+	}
+	content, err := fs.ReadFile(store, file)
+	if err != nil {
+		return ""
+	}
+	if column > 0 {
+		// The column of the template engine is the byte offset in the line, the snippet marker starts with 1.
+		column++
+	}
+	return snippet(content, line, column)
+}
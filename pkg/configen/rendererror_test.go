@@ -0,0 +1,80 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_GenerateFileRenderError(t *testing.T) {
+	tests := []struct {
+		templateFolder string
+		want           *RenderError
+	}{
+		{
+			templateFolder: "g22",
+			want: &RenderError{
+				Template: "main.gotext",
+				Line:     2,
+				Column:   2,
+				Action:   "index .ports 3",
+				Message:  "error calling index: index out of range: 3",
+				Snippet:  "  1 | Hi {{.name}}!\n> 2 | {{index .ports 3}}\n    |   ^\n  3 | bye\n  4 | \n",
+			},
+		},
+		{
+			templateFolder: "t14",
+			want: &RenderError{
+				Template: "main.gotext",
+				Line:     2,
+				Message:  "unexpected {{end}}",
+				Snippet:  "  1 | Hi {{.name}}!\n> 2 | Ports: {{end}}\n  3 | \n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.templateFolder, func(t *testing.T) {
+			is := require.New(t)
+			r := NewRepository("testdata/templates")
+			var variables map[string]interface{}
+			_ = util.ReadJSONObject("testdata/templates/"+tt.templateFolder+"/variables.json", &variables)
+
+			_, _, err := r.GenerateFile(context.Background(), tt.templateFolder, variables)
+			is.True(errors.Is(err, ErrRender), "%v", err)
+			var renderError *RenderError
+			is.True(errors.As(err, &renderError))
+			renderError.Err = nil
+			is.Equal(tt.want, renderError)
+		})
+	}
+}
+
+func TestNewRenderError(t *testing.T) {
+	is := require.New(t)
+	is.Nil(newRenderError(errors.New("output too large"), nil, nil))
+
+	renderError := newRenderError(errors.New(`template: main.gotext: no such template "footer"`), nil, nil)
+	is.NotNil(renderError)
+	is.Equal("main.gotext", renderError.Template)
+	is.Equal(0, renderError.Line)
+	is.Equal(`no such template "footer"`, renderError.Message)
+	is.Equal(`render error in template main.gotext at line 0, column 0: no such template "footer"`, renderError.Error())
+}
@@ -17,12 +17,14 @@
 package configen
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"os"
-	"regexp"
-
-	"github.com/tidwall/pretty"
+	"path"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -31,111 +33,297 @@ import (
 
 // Repository to generate files via templates
 type Repository struct {
-	path string
+	store TemplateStore
+	// mutex guards the template folder, so that templates are not loaded from half replaced folders.
+	mutex sync.RWMutex
+	cache *templateCache
+	// sandbox blocks the functions giving access to the server in all templates.
+	sandbox bool
+	// renderTimeout limits the time of a single template execution.
+	renderTimeout time.Duration
+	// maxOutputSize limits the size of a single generated output.
+	maxOutputSize int64
+}
+
+// NewRepository creates a new code generation repository of the templates in the folder
+func NewRepository(templatePath string, options ...Option) *Repository {
+	return NewStoreRepository(NewDirStore(templatePath), options...)
+}
+
+// NewStoreRepository creates a new code generation repository of the templates in the template store
+func NewStoreRepository(store TemplateStore, options ...Option) *Repository {
+	r := &Repository{
+		store: store,
+		cache: newTemplateCache(cacheWatchInterval),
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
 }
 
-// NewRepository creates a new code generation repository
-func NewRepository(templatePath string) *Repository {
-	return &Repository{path: templatePath}
+// Close stops watching the templates for changes, the repository can still be used afterwards.
+func (r *Repository) Close() {
+	r.cache.close()
 }
 
 // TemplateEngine allow to generate files
 type TemplateEngine interface {
-	// GenerateFile executes a template and added a variable set
-	GenerateFile(config *TemplateConfig, data map[string]interface{}) ([]byte, string, error)
+	// Parse parses all templates of the template config
+	Parse(config *TemplateConfig) (ParsedTemplate, error)
 }
 
-func (r *Repository) newEngine(name Engine) (TemplateEngine, error) {
-	switch name {
-	case EngineGolang:
-		return newGoEngine()
-	case "":
-		return newGoEngine()
-	}
-	return nil, errors.WithMessage(ErrEngineNotFound, string(name))
+// ParsedTemplate is a parsed set of templates which can be executed
+type ParsedTemplate interface {
+	// Execute executes the named template with the variable set and writes the output to the writer.
+	// The execution has to stop when the context is done.
+	Execute(ctx context.Context, w io.Writer, templateName string, data map[string]interface{}) error
 }
 
 // GenerateFile executes a template and added a variable set
-func (r Repository) GenerateFile(templateFolder string, variables map[string]interface{}) ([]byte, string, error) {
-	config, err := parseConfigFile(r.path, templateFolder)
+func (r *Repository) GenerateFile(ctx context.Context, templateFolder string, variables map[string]interface{}) ([]byte, string, error) {
+	template, err := r.getTemplate(templateFolder)
 	if err != nil {
 		return nil, "", err
 	}
-	engine, err := r.newEngine(config.TemplateEngine)
-	if err != nil {
-		return nil, "", err
+	if len(template.config.Outputs) > 0 {
+		return nil, "", errors.WithMessage(ErrMultipleOutputs, templateFolder)
 	}
-	result, format, err := engine.GenerateFile(config, variables)
+	output := template.outputs[0]
+	variables = template.effectiveVariables(variables)
+	if err := template.validateVariables(variables); err != nil {
+		return nil, output.format, err
+	}
+	result, err := template.render(ctx, output, variables)
+	return result, output.format, err
+}
+
+// GenerateBundle executes all outputs of a template with the variable set.
+// Templates without declared outputs generate a bundle with a single file.
+func (r *Repository) GenerateBundle(ctx context.Context, templateFolder string, variables map[string]interface{}) (*Bundle, error) {
+	template, err := r.getTemplate(templateFolder)
 	if err != nil {
-		return result, format, err
+		return nil, err
 	}
-	for _, postProcessorName := range config.PostProcessors {
-		processor, ok := postProcessors[postProcessorName]
-		if !ok {
-			return nil, "", errors.WithMessage(ErrPostProcessorNotFound, postProcessorName)
-		}
-		result, err = processor(result)
+	variables = template.effectiveVariables(variables)
+	if err := template.validateVariables(variables); err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{MultiFile: len(template.config.Outputs) > 0}
+	for _, output := range template.outputs {
+		result, err := template.render(ctx, output, variables)
 		if err != nil {
-			return result, format, err
+			return nil, errors.WithMessage(err, output.name)
 		}
+		bundle.Files = append(bundle.Files, &BundleFile{Name: output.name, Format: output.format, Content: result})
 	}
-	return result, format, err
+	return bundle, nil
 }
-func parseConfigFile(templatePath string, templateFolder string) (*TemplateConfig, error) {
-	configFile := fmt.Sprintf("%s/%s/config.yaml", templatePath, templateFolder)
-	configFileFD, err := os.Open(configFile)
+
+// CacheStats returns the statistics of the parsed template cache.
+func (r *Repository) CacheStats() CacheStats {
+	return r.cache.stats()
+}
+
+// getTemplate returns the parsed template of the template folder.
+// The lock only guards the loading, parsed templates don't change when the template folder is replaced,
+// so that running generations don't block template changes.
+func (r *Repository) getTemplate(templateFolder string) (*cachedTemplate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.loadTemplate(templateFolder)
+}
+
+// loadTemplate returns the parsed template of the template folder.
+// Parsed templates are cached until one of their files changes.
+func (r *Repository) loadTemplate(templateFolder string) (*cachedTemplate, error) {
+	if template := r.cache.get(templateFolder); template != nil {
+		return template, nil
+	}
+	config, err := parseConfigFile(r.store, templateFolder)
 	if err != nil {
-		log.Error().Err(err).Str("config_file", configFile).Msg("not able to read config file for templating")
-		return nil, errors.WithMessage(ErrTemplateConfigNotFound, configFile)
+		return nil, err
 	}
-	log.Debug().Str("config_file", configFile).Msg("successfully opened")
-	defer func() { _ = configFileFD.Close() }()
-
-	byteValue, err := ioutil.ReadAll(configFileFD)
+	config.sandbox = r.sandbox
+	config.store = r.store
+	engine, err := newEngine(config)
 	if err != nil {
 		return nil, err
 	}
-	config := &TemplateConfig{}
-	err = yaml.Unmarshal(byteValue, config)
+	template := &cachedTemplate{
+		config:        config,
+		store:         r.store,
+		files:         make(map[string]fileStamp),
+		renderTimeout: r.renderTimeout,
+		maxOutputSize: stricterSize(r.maxOutputSize, config.MaxOutputSize),
+	}
+	if len(config.RenderTimeout) > 0 {
+		timeout, _ := time.ParseDuration(config.RenderTimeout)
+		template.renderTimeout = stricterTimeout(r.renderTimeout, timeout)
+	}
+	for _, folder := range append([]string{templateFolder}, config.parents...) {
+		template.watch(fmt.Sprintf("%s/config.yaml", folder))
+		template.watch(fmt.Sprintf("%s/%s", folder, defaultsFile))
+		template.watch(fmt.Sprintf("%s/%s", folder, featuresFile))
+	}
+	template.watch(featuresFile)
+	for _, parent := range config.parents {
+		// New versions of a versioned parent replace the extended version.
+		if name, version := splitVersion(r.store, parent); len(version) > 0 {
+			template.watchPattern(name + "/*")
+		}
+	}
+	for _, pattern := range config.Patterns() {
+		template.watchPattern(pattern)
+	}
+	folders := dataFolders(templateFolder, config.parents)
+	for _, folder := range folders {
+		template.watchPattern(folder + "/*")
+	}
+	if config.data, err = readData(r.store, folders); err != nil {
+		return nil, err
+	}
+	template.templates, err = engine.Parse(config)
 	if err != nil {
 		return nil, err
 	}
-	if len(config.MainPattern) > 0 {
-		config.MainPattern = fmt.Sprintf("%s/%s/%s", templatePath, templateFolder, config.MainPattern)
+	for _, outputConfig := range config.resolvedOutputs() {
+		output := &templateOutput{
+			name:         outputConfig.Name,
+			mainTemplate: outputConfig.MainTemplate,
+			format:       outputConfig.OutputFormat,
+		}
+		if output.postProcessors, err = newPostProcessors(outputConfig.PostProcessors); err != nil {
+			return nil, err
+		}
+		template.outputs = append(template.outputs, output)
 	}
-	if len(config.IncludePattern) > 0 {
-		config.IncludePattern = fmt.Sprintf("%s/%s", templatePath, config.IncludePattern)
+	if len(config.VariablesSchema) > 0 {
+		template.watch(config.VariablesSchema)
+		if template.schema, err = readSchemaFile(r.store, config.VariablesSchema); err != nil {
+			return nil, err
+		}
 	}
-	return config, err
+	r.cache.put(templateFolder, template)
+	return template, nil
 }
 
-type postProcess func(value []byte) ([]byte, error)
-
-var (
-	postProcessors = map[string]postProcess{
-		"removeTrailingCommas": removeTrailingCommas,
-		"removeEmptyLines":     removeEmptyLines,
-		"prettyJSON":           prettyJSON,
-		"uglyJSON":             uglyJSON,
+func parseConfigFile(store TemplateStore, templateFolder string) (*TemplateConfig, error) {
+	config, err := resolveConfigFile(store, templateFolder, nil)
+	if err != nil {
+		return nil, err
 	}
-	removeTrailingCommasPattern = regexp.MustCompile(`(\s*),(\s*[}\]])`)
-	removeEmptyLinesPattern     = regexp.MustCompile(`\n(( )*\n)+`)
-)
-
-func removeTrailingCommas(value []byte) ([]byte, error) {
-	return []byte(removeTrailingCommasPattern.ReplaceAllString(string(value), "$1$2")), nil
+	// The features of the template path apply to all templates.
+	globalFeatures, err := readFeaturesFile(store, featuresFile)
+	if err != nil {
+		return nil, err
+	}
+	config.features = mergeFeatures(globalFeatures, config.features)
+	return config, nil
 }
 
-func removeEmptyLines(value []byte) ([]byte, error) {
-	return []byte(removeEmptyLinesPattern.ReplaceAllString(string(value), "\n")), nil
+// resolveConfigFile reads the config of the template folder and merges it with the configs of its parents.
+// The chain holds the templates extending this template, it is used to detect cycles.
+func resolveConfigFile(store TemplateStore, templateFolder string, chain []string) (*TemplateConfig, error) {
+	config, err := readConfigFile(store, templateFolder)
+	if err != nil {
+		return nil, err
+	}
+	// The patterns are resolved to paths of the template store, which are always clean.
+	if len(config.MainPattern) > 0 {
+		config.MainPattern = path.Join(templateFolder, config.MainPattern)
+	}
+	if len(config.IncludePattern) > 0 {
+		config.IncludePattern = path.Clean(config.IncludePattern)
+	}
+	if len(config.VariablesSchema) > 0 {
+		config.VariablesSchema = path.Join(templateFolder, config.VariablesSchema)
+	}
+	fileDefaults, err := readDefaultsFile(store, templateFolder)
+	if err != nil {
+		return nil, err
+	}
+	if len(fileDefaults) > 0 {
+		config.Defaults = mergeVariables(fileDefaults, config.Defaults, config.ListMerge)
+	}
+	if config.features, err = readFeaturesFile(store, fmt.Sprintf("%s/%s", templateFolder, featuresFile)); err != nil {
+		return nil, err
+	}
+	if len(config.Extends) > 0 {
+		chain = append(chain, templateFolder)
+		if !isValidTemplateName(config.Extends) {
+			return nil, errors.WithMessagef(ErrInvalidExtends, "%s extends invalid template name %s", templateFolder, config.Extends)
+		}
+		// Versioned parents are extended in their highest version.
+		parentFolder, err := latestTemplateFolder(store, config.Extends)
+		if err != nil {
+			return nil, err
+		}
+		if containsString(chain, parentFolder) {
+			return nil, errors.WithMessagef(ErrInvalidExtends, "cycle %s -> %s", strings.Join(chain, " -> "), parentFolder)
+		}
+		parent, err := resolveConfigFile(store, parentFolder, chain)
+		if errors.Is(err, ErrTemplateConfigNotFound) {
+			return nil, errors.WithMessagef(ErrInvalidExtends, "%s extends missing template %s", templateFolder, config.Extends)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if config.Delimiters != nil && config.delimiters() != parent.delimiters() {
+			return nil, errors.WithMessagef(ErrInvalidDelimiters, "%s uses %s %s but extends %s with %s %s", templateFolder,
+				config.Delimiters.Left, config.Delimiters.Right, config.Extends, parent.delimiters().Left, parent.delimiters().Right)
+		}
+		config.inherit(parent)
+		config.parents = append([]string{parentFolder}, parent.parents...)
+	}
+	if err := validateListMerge(config.ListMerge); err != nil {
+		return nil, err
+	}
+	if config.Delimiters != nil && (len(config.Delimiters.Left) == 0 || len(config.Delimiters.Right) == 0) {
+		return nil, errors.WithMessage(ErrInvalidDelimiters, "left and right delimiter have to be set")
+	}
+	if len(config.RenderTimeout) > 0 {
+		if timeout, err := time.ParseDuration(config.RenderTimeout); err != nil || timeout <= 0 {
+			return nil, errors.WithMessagef(ErrInvalidLimits, "render_timeout %s", config.RenderTimeout)
+		}
+	}
+	if config.MaxOutputSize < 0 {
+		return nil, errors.WithMessagef(ErrInvalidLimits, "max_output_size %d", config.MaxOutputSize)
+	}
+	switch config.MissingKey {
+	case "", MissingKeyModeDefault, MissingKeyModeZero, MissingKeyModeError:
+	default:
+		return nil, errors.WithMessage(ErrInvalidMissingKey, string(config.MissingKey))
+	}
+	// Report unknown post processors and invalid arguments when the config is loaded.
+	if _, err := newPostProcessors(config.PostProcessors); err != nil {
+		return nil, err
+	}
+	if err := validateOutputs(config.Outputs); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
-func prettyJSON(value []byte) ([]byte, error) {
-	out := pretty.Pretty(value)
-	return out, nil
-}
+// readConfigFile reads the config.yaml of the template folder without resolving the patterns.
+func readConfigFile(store TemplateStore, templateFolder string) (*TemplateConfig, error) {
+	configFile := fmt.Sprintf("%s/config.yaml", templateFolder)
+	configFileFD, err := store.Open(configFile)
+	if err != nil {
+		log.Error().Err(err).Str("config_file", configFile).Msg("not able to read config file for templating")
+		return nil, errors.WithMessage(ErrTemplateConfigNotFound, configFile)
+	}
+	log.Debug().Str("config_file", configFile).Msg("successfully opened")
+	defer func() { _ = configFileFD.Close() }()
 
-func uglyJSON(value []byte) ([]byte, error) {
-	out := pretty.UglyInPlace(value)
-	return out, nil
+	byteValue, err := ioutil.ReadAll(configFileFD)
+	if err != nil {
+		return nil, err
+	}
+	config := &TemplateConfig{}
+	err = yaml.Unmarshal(byteValue, config)
+	if err != nil {
+		return nil, errors.WithMessagef(ErrInvalidTemplate, "%s: %v", configFile, err)
+	}
+	return config, nil
 }
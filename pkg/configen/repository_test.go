@@ -17,6 +17,7 @@
 package configen
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -50,8 +51,8 @@ func Test_parseConfigFile(t *testing.T) {
 			args: args{templatePath: "testdata/templates", templateFolder: "t1"},
 			want: &TemplateConfig{
 				TemplateEngine: "golang",
-				MainPattern:    "testdata/templates/t1/*.goyaml",
-				IncludePattern: "testdata/templates/includes/*.goyaml",
+				MainPattern:    "t1/*.goyaml",
+				IncludePattern: "includes/*.goyaml",
 				MainTemplate:   "main.goyaml",
 			},
 		}, {
@@ -62,12 +63,75 @@ func Test_parseConfigFile(t *testing.T) {
 				IncludePattern: "",
 				MainTemplate:   "main.goyaml",
 			},
+		}, {
+			args: args{templatePath: "testdata/templates", templateFolder: "t3"},
+			want: &TemplateConfig{
+				TemplateEngine: "golang",
+				MainPattern:    "t3/*.gojson",
+				MainTemplate:   "main.gojson",
+				PostProcessors: []PostProcessorConfig{
+					{Name: "removeTrailingCommas"},
+					{Name: "indentJSON", Args: map[string]interface{}{"indent": 4}},
+				},
+			},
+		}, {
+			args:      args{templatePath: "testdata/templates", templateFolder: "t4"},
+			wantErr:   true,
+			wantedErr: ErrPostProcessorNotFound,
+		}, {
+			args:      args{templatePath: "testdata/templates", templateFolder: "t5"},
+			wantErr:   true,
+			wantedErr: ErrInvalidPostProcessorArgs,
+		},
+		{
+			args: args{templatePath: "testdata/templates", templateFolder: "g10"},
+			want: &TemplateConfig{
+				MainPattern:       "g10/*.gotext",
+				MainTemplate:      "main.gotext",
+				OutputFormat:      "txt",
+				PostProcessors:    []PostProcessorConfig{{Name: "removeEmptyLines"}},
+				Extends:           "g9",
+				parents:           []string{"g9"},
+				inheritedPatterns: []string{"g9/*.gotext"},
+			},
+		}, {
+			args:      args{templatePath: "testdata/templates", templateFolder: "t6"},
+			wantErr:   true,
+			wantedErr: ErrInvalidExtends,
+		}, {
+			args:      args{templatePath: "testdata/templates", templateFolder: "t8"},
+			wantErr:   true,
+			wantedErr: ErrInvalidExtends,
+		},
+		{
+			args:      args{templatePath: "testdata/templates", templateFolder: "t9"},
+			wantErr:   true,
+			wantedErr: ErrInvalidMissingKey,
+		},
+		{
+			args:      args{templatePath: "testdata/templates", templateFolder: "t10"},
+			wantErr:   true,
+			wantedErr: ErrInvalidDelimiters,
+		}, {
+			args:      args{templatePath: "testdata/templates", templateFolder: "t11"},
+			wantErr:   true,
+			wantedErr: ErrInvalidDelimiters,
+		},
+		{
+			args:      args{templatePath: "testdata/templates", templateFolder: "t12"},
+			wantErr:   true,
+			wantedErr: ErrInvalidLimits,
+		},
+		{
+			args:      args{templatePath: "testdata/templates", templateFolder: "t13"},
+			wantErr:   true,
+			wantedErr: ErrInvalidFeatures,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			is := require.New(t)
-			got, err := parseConfigFile(tt.args.templatePath, tt.args.templateFolder)
+			got, err := parseConfigFile(NewDirStore(tt.args.templatePath), tt.args.templateFolder)
 			if tt.wantErr {
 				if err == nil {
 					t.Errorf("parseConfigFile() error = %v, wantErr %v", err, tt.wantErr)
@@ -78,7 +142,7 @@ func Test_parseConfigFile(t *testing.T) {
 				return
 			}
 			is.NoError(err)
-			if diff := cmp.Diff(tt.want, got); diff != "" {
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(TemplateConfig{})); diff != "" {
 				t.Errorf("mismatch (-want +got):\n%s", diff)
 			}
 		})
@@ -120,6 +184,56 @@ func TestRepository_GenerateFile(t *testing.T) {
 			templateFolder: "g4",
 			wantErr:        false,
 			want:           []byte(`{"a":"Feature A enabled","A":"Feature A enabled"}`),
+		}, {
+			templatePath:   "testdata/templates",
+			templateFolder: "g5",
+			wantErr:        true,
+			wantedErr:      ErrInvalidVariables,
+		}, {
+			templatePath:   "testdata/templates",
+			templateFolder: "g7",
+			wantErr:        true,
+			wantedErr:      ErrInvalidOutput,
+		},
+		{
+			templatePath:   "testdata/templates",
+			templateFolder: "g9",
+			format:         "txt",
+			want:           []byte("Hello Chris!\nbase footer"),
+		}, {
+			templatePath:   "testdata/templates",
+			templateFolder: "g10",
+			format:         "txt",
+			want:           []byte("Hi Chris!\nbase footer"),
+		},
+		{
+			templatePath:   "testdata/templates",
+			templateFolder: "g11",
+			want:           []byte("leaf1@fra1 ntp 10.0.0.1 10.0.0.2 prefer=true"),
+		},
+		{
+			templatePath:   "testdata/templates",
+			templateFolder: "g12",
+			wantErr:        true,
+			wantedErr:      ErrMissingKey,
+		}, {
+			templatePath:   "testdata/templates",
+			templateFolder: "g13",
+			want:           []byte("Hi Chris!"),
+		},
+		{
+			templatePath:   "testdata/templates",
+			templateFolder: "g14",
+			want:           []byte("hostname {{ hostname }} Chris\nfooter"),
+		}, {
+			templatePath:   "testdata/templates",
+			templateFolder: "g15",
+			wantErr:        true,
+			wantedErr:      ErrInvalidDelimiters,
+		}, {
+			templatePath:   "testdata/templates",
+			templateFolder: "g21",
+			want:           []byte("evpn=false srv6=false local-pref=true"),
 		},
 	}
 	for _, tt := range tests {
@@ -131,7 +245,7 @@ func TestRepository_GenerateFile(t *testing.T) {
 			variablesFile := fmt.Sprintf("%s/%s/%s", tt.templatePath, tt.templateFolder, "variables.json")
 			_ = util.ReadJSONObject(variablesFile, &variables)
 
-			got, format, err := r.GenerateFile(tt.templateFolder, variables)
+			got, format, err := r.GenerateFile(context.Background(), tt.templateFolder, variables)
 			if tt.wantErr {
 				if err == nil {
 					t.Errorf("parseConfigFile() error = %v, wantErr %v", err, tt.wantErr)
@@ -295,3 +409,29 @@ func Test_uglyJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestRepository_GenerateFileMissingKey(t *testing.T) {
+	is := require.New(t)
+	r := NewRepository("testdata/templates")
+	_, _, err := r.GenerateFile(context.Background(), "g12", map[string]interface{}{"name": "Chris", "device": map[string]interface{}{}})
+	var missingKeyError *MissingKeyError
+	is.True(errors.As(err, &missingKeyError))
+	is.Equal("site", missingKeyError.Key)
+	is.Equal(".device.site", missingKeyError.Expression)
+	is.Equal("site.gotext", missingKeyError.Template)
+	is.Equal(1, missingKeyError.Line)
+	is.Equal(32, missingKeyError.Column)
+	is.Equal("> 1 | {{define \"site\"}}Site: {{.device.site}}{{end}}\n    |                                 ^\n", missingKeyError.Snippet)
+}
+
+func TestRepository_GenerateFileZeroMissingKeys(t *testing.T) {
+	is := require.New(t)
+	r := NewStoreRepository(NewMemoryStore(map[string][]byte{
+		"zero/config.yaml": []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\nmissing_key: zero\n"),
+		"zero/main.gotext": []byte(`{{define "site"}}[{{.site}}]{{end}}{{.name}} {{.missing}} {{"<no value>"}} {{template "site" .}}{{range .ports}}{{.speed}};{{end}}`),
+	}))
+	got, _, err := r.GenerateFile(context.Background(), "zero", map[string]interface{}{
+		"name": "Chris", "ports": []interface{}{map[string]interface{}{"speed": 100}, map[string]interface{}{}}})
+	is.NoError(err)
+	is.Equal("Chris  <no value> []100;;", string(got))
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"reflect"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/pkg/errors"
+)
+
+// sandboxDeniedFunctions are the functions which give access to the server, they are blocked in sandbox mode.
+var sandboxDeniedFunctions = []string{"env", "expandenv", "getHostByName"}
+
+// isFunctionAllowed checks the function against the function allow and deny list of the template.
+func (c *TemplateConfig) isFunctionAllowed(name string) bool {
+	if c.sandbox && containsString(sandboxDeniedFunctions, name) {
+		return false
+	}
+	if c.Functions == nil {
+		return true
+	}
+	if len(c.Functions.Allow) > 0 && !containsString(c.Functions.Allow, name) {
+		return false
+	}
+	return !containsString(c.Functions.Deny, name)
+}
+
+// checkFunctions reports the first function call of the templates which isn't allowed for the template.
+func checkFunctions(templates *template.Template, config *TemplateConfig, funcs template.FuncMap) error {
+	for _, t := range templates.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		tree := t.Tree
+		var err error
+		walkNodes(tree.Root, func(node parse.Node) bool {
+			identifier, ok := node.(*parse.IdentifierNode)
+			if !ok {
+				return true
+			}
+			if _, ok := funcs[identifier.Ident]; ok && !config.isFunctionAllowed(identifier.Ident) {
+				location, _ := tree.ErrorContext(identifier)
+				err = errors.WithMessagef(ErrFunctionNotAllowed, "function %s in %s", identifier.Ident, location)
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkNodes calls visit for the node and all nodes below it until visit returns false.
+func walkNodes(node parse.Node, visit func(parse.Node) bool) bool {
+	if !visit(node) {
+		return false
+	}
+	var children []parse.Node
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			children = n.Nodes
+		}
+	case *parse.ActionNode:
+		children = []parse.Node{n.Pipe}
+	case *parse.IfNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.RangeNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.WithNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.TemplateNode:
+		children = []parse.Node{n.Pipe}
+	case *parse.PipeNode:
+		if n != nil {
+			for _, command := range n.Cmds {
+				children = append(children, command)
+			}
+		}
+	case *parse.CommandNode:
+		children = n.Args
+	case *parse.ChainNode:
+		children = []parse.Node{n.Node}
+	}
+	for _, child := range children {
+		if isNilNode(child) {
+			continue
+		}
+		if !walkNodes(child, visit) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNilNode checks for unset nodes, e.g. the missing else list of an if node.
+func isNilNode(node parse.Node) bool {
+	if node == nil {
+		return true
+	}
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
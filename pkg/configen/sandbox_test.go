@@ -0,0 +1,103 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_GenerateFileFunctions(t *testing.T) {
+	is := require.New(t)
+	is.NoError(os.Setenv("SANDBOX_TEST", "secret"))
+	defer func() { _ = os.Unsetenv("SANDBOX_TEST") }()
+
+	tests := []struct {
+		name           string
+		templateFolder string
+		sandbox        bool
+		want           string
+		wantedErr      error
+	}{
+		{
+			name:           "env without sandbox",
+			templateFolder: "g16",
+			want:           "home=secret",
+		}, {
+			name:           "env in sandbox",
+			templateFolder: "g16",
+			sandbox:        true,
+			wantedErr:      ErrFunctionNotAllowed,
+		}, {
+			name:           "function not in allow list",
+			templateFolder: "g17",
+			wantedErr:      ErrFunctionNotAllowed,
+		}, {
+			name:           "function in deny list",
+			templateFolder: "g18",
+			wantedErr:      ErrFunctionNotAllowed,
+		}, {
+			name:           "sandbox allows other functions",
+			templateFolder: "g4",
+			sandbox:        true,
+			want:           `{"a":"Feature A enabled","A":"Feature A enabled"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			r := NewRepository("testdata/templates", WithSandbox(tt.sandbox))
+			var variables map[string]interface{}
+			_ = util.ReadJSONObject(fmt.Sprintf("testdata/templates/%s/variables.json", tt.templateFolder), &variables)
+			got, _, err := r.GenerateFile(context.Background(), tt.templateFolder, variables)
+			if tt.wantedErr != nil {
+				is.True(errors.Is(err, tt.wantedErr), "error = %v, wantedErr %v", err, tt.wantedErr)
+				return
+			}
+			is.NoError(err)
+			is.Equal(tt.want, string(got))
+		})
+	}
+}
+
+func TestTemplateConfig_isFunctionAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   TemplateConfig
+		function string
+		want     bool
+	}{
+		{name: "no restrictions", function: "env", want: true},
+		{name: "sandbox", config: TemplateConfig{sandbox: true}, function: "expandenv", want: false},
+		{name: "sandbox allows others", config: TemplateConfig{sandbox: true}, function: "upper", want: true},
+		{name: "allowed", config: TemplateConfig{Functions: &FunctionsConfig{Allow: []string{"upper"}}}, function: "upper", want: true},
+		{name: "not allowed", config: TemplateConfig{Functions: &FunctionsConfig{Allow: []string{"upper"}}}, function: "lower", want: false},
+		{name: "denied", config: TemplateConfig{Functions: &FunctionsConfig{Deny: []string{"upper"}}}, function: "upper", want: false},
+		{name: "sandbox wins over allow list", config: TemplateConfig{sandbox: true, Functions: &FunctionsConfig{Allow: []string{"env"}}}, function: "env", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.config.isFunctionAllowed(tt.function))
+		})
+	}
+}
@@ -0,0 +1,549 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Violation is a single violation of the variables schema.
+type Violation struct {
+	// Path is the JSON pointer to the violating value (e.g. /interfaces/0/name).
+	Path string `json:"path"`
+	// Message describes the violation.
+	Message string `json:"message"`
+}
+
+// VariablesError is returned if the variables don't match the variables schema of the template.
+type VariablesError struct {
+	Violations []Violation
+}
+
+func (e *VariablesError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, fmt.Sprintf("%s: %s", violation.Path, violation.Message))
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidVariables, strings.Join(messages, ", "))
+}
+
+// Unwrap allows to check the error with errors.Is(err, ErrInvalidVariables).
+func (e *VariablesError) Unwrap() error {
+	return ErrInvalidVariables
+}
+
+// jsonSchema validates variables against a JSON schema.
+// The supported keywords are a subset of JSON schema draft 7:
+// type, enum, const, properties, required, additionalProperties, items, minItems, maxItems, uniqueItems,
+// minLength, maxLength, pattern, format (ipv4, ipv6), minimum, maximum, exclusiveMinimum, exclusiveMaximum,
+// multipleOf, allOf, anyOf, oneOf, not and local $ref. Schemas with other keywords or formats are rejected.
+type jsonSchema struct {
+	root     interface{}
+	patterns map[string]*regexp.Regexp
+	// acyclic holds the schemas whose references were checked for cycles.
+	acyclic map[uintptr]bool
+}
+
+// readSchemaFile reads and parses a JSON schema file.
+func readSchemaFile(store TemplateStore, schemaFile string) (*jsonSchema, error) {
+	data, err := fs.ReadFile(store, schemaFile)
+	if err != nil {
+		return nil, errors.WithMessage(ErrInvalidSchema, err.Error())
+	}
+	return newJSONSchema(data)
+}
+
+// newJSONSchema parses the JSON schema and compiles all its patterns.
+func newJSONSchema(data []byte) (*jsonSchema, error) {
+	s := &jsonSchema{patterns: make(map[string]*regexp.Regexp), acyclic: make(map[uintptr]bool)}
+	if err := json.Unmarshal(data, &s.root); err != nil {
+		return nil, errors.WithMessage(ErrInvalidSchema, err.Error())
+	}
+	if err := s.compile(s.root, "#"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Keywords of the supported JSON schema subset by the kind of their value.
+var (
+	// schemaAnnotations are accepted, but don't affect the validation.
+	schemaAnnotations = []string{"$schema", "$id", "$comment", "title", "description", "default", "examples", "readOnly", "writeOnly"}
+	// schemaAssertions have plain values.
+	schemaAssertions = []string{"type", "enum", "const", "required", "minItems", "maxItems", "uniqueItems", "minLength", "maxLength",
+		"pattern", "format", "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf", "$ref"}
+	// schemaSubschemas have a schema as value.
+	schemaSubschemas = []string{"additionalProperties", "items", "not"}
+	// schemaSubschemaLists have a list of schemas as value.
+	schemaSubschemaLists = []string{"allOf", "anyOf", "oneOf"}
+	// schemaSubschemaMaps have an object of schemas as value.
+	schemaSubschemaMaps = []string{"properties", "definitions", "$defs"}
+	// schemaFormats are the supported formats.
+	schemaFormats = []string{"ipv4", "ipv6"}
+)
+
+// compile compiles the patterns of the schema and rejects the keywords and formats which are not supported,
+// so that no part of a schema is silently ignored. The pointer locates the schema in the schema file.
+func (s *jsonSchema) compile(node interface{}, pointer string) error {
+	if _, ok := node.(bool); ok {
+		return nil
+	}
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		return errors.WithMessagef(ErrInvalidSchema, "schema at %s is not an object", pointer)
+	}
+	keywords := make([]string, 0, len(schema))
+	for keyword := range schema {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		value, keywordPointer := schema[keyword], pointer+"/"+escapePointer(keyword)
+		switch {
+		case containsString(schemaAnnotations, keyword):
+		case containsString(schemaAssertions, keyword):
+			if err := s.compileAssertion(schema, keyword, keywordPointer); err != nil {
+				return err
+			}
+		case containsString(schemaSubschemas, keyword):
+			if _, ok := value.([]interface{}); ok && keyword == "items" {
+				return errors.WithMessagef(ErrInvalidSchema, "tuple items at %s are not supported", keywordPointer)
+			}
+			if err := s.compile(value, keywordPointer); err != nil {
+				return err
+			}
+		case containsString(schemaSubschemaLists, keyword):
+			list, ok := value.([]interface{})
+			if !ok {
+				return errors.WithMessagef(ErrInvalidSchema, "%s is not a list", keywordPointer)
+			}
+			for i, sub := range list {
+				if err := s.compile(sub, fmt.Sprintf("%s/%d", keywordPointer, i)); err != nil {
+					return err
+				}
+			}
+		case containsString(schemaSubschemaMaps, keyword):
+			subs, ok := value.(map[string]interface{})
+			if !ok {
+				return errors.WithMessagef(ErrInvalidSchema, "%s is not an object", keywordPointer)
+			}
+			names := make([]string, 0, len(subs))
+			for name := range subs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if err := s.compile(subs[name], keywordPointer+"/"+escapePointer(name)); err != nil {
+					return err
+				}
+			}
+		default:
+			return errors.WithMessagef(ErrInvalidSchema, "unsupported keyword %s at %s", keyword, pointer)
+		}
+	}
+	return nil
+}
+
+// compileAssertion checks the value of an assertion keyword.
+func (s *jsonSchema) compileAssertion(schema map[string]interface{}, keyword string, pointer string) error {
+	switch keyword {
+	case "pattern":
+		pattern, _ := schema[keyword].(string)
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return errors.WithMessagef(ErrInvalidSchema, "%s: %v", pointer, err)
+		}
+		s.patterns[pattern] = re
+	case "format":
+		if format, _ := schema[keyword].(string); !containsString(schemaFormats, format) {
+			return errors.WithMessagef(ErrInvalidSchema, "unsupported format %v at %s", schema[keyword], pointer)
+		}
+	case "$ref":
+		return s.checkCycles(schema, make(map[uintptr]bool))
+	}
+	return nil
+}
+
+// checkCycles rejects references which lead back to the schema without descending into the value,
+// the validation of such references would never end.
+// The chain holds the schemas which are applied to the same value as this schema.
+func (s *jsonSchema) checkCycles(node interface{}, chain map[uintptr]bool) error {
+	schema, ok := node.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	id := reflect.ValueOf(schema).Pointer()
+	if s.acyclic[id] {
+		return nil
+	}
+	if chain[id] {
+		return errors.WithMessage(ErrInvalidSchema, "circular reference")
+	}
+	chain[id] = true
+	defer delete(chain, id)
+	if ref, ok := schema["$ref"].(string); ok {
+		referenced, err := s.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if err := s.checkCycles(referenced, chain); err != nil {
+			return errors.WithMessage(err, ref)
+		}
+	}
+	// The combined schemas are applied to the same value.
+	sameValue := make([]interface{}, 0)
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		if subs, ok := schema[keyword].([]interface{}); ok {
+			sameValue = append(sameValue, subs...)
+		}
+	}
+	if not, ok := schema["not"]; ok {
+		sameValue = append(sameValue, not)
+	}
+	for _, sub := range sameValue {
+		if err := s.checkCycles(sub, chain); err != nil {
+			return err
+		}
+	}
+	s.acyclic[id] = true
+	return nil
+}
+
+// resolve returns the schema referenced by a local JSON pointer (e.g. #/definitions/interface).
+func (s *jsonSchema) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, errors.WithMessagef(ErrInvalidSchema, "only local references are supported: %s", ref)
+	}
+	node := s.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, errors.WithMessagef(ErrInvalidSchema, "unresolvable reference: %s", ref)
+		}
+		if node, ok = object[token]; !ok {
+			return nil, errors.WithMessagef(ErrInvalidSchema, "unresolvable reference: %s", ref)
+		}
+	}
+	return node, nil
+}
+
+// validate checks the variables and returns a *VariablesError with all violations.
+func (s *jsonSchema) validate(variables map[string]interface{}) error {
+	var value interface{} = variables
+	if variables == nil {
+		value = map[string]interface{}{}
+	}
+	violations := s.validateValue(s.root, value, "")
+	if len(violations) > 0 {
+		return &VariablesError{Violations: violations}
+	}
+	return nil
+}
+
+func (s *jsonSchema) validateValue(node interface{}, value interface{}, path string) []Violation {
+	switch n := node.(type) {
+	case bool:
+		if !n {
+			return []Violation{{Path: path, Message: "no value is allowed"}}
+		}
+		return nil
+	case map[string]interface{}:
+		return s.validateObjectSchema(n, value, path)
+	}
+	return nil
+}
+
+func (s *jsonSchema) validateObjectSchema(schema map[string]interface{}, value interface{}, path string) []Violation {
+	if ref, ok := schema["$ref"].(string); ok {
+		referenced, err := s.resolve(ref)
+		if err != nil {
+			return []Violation{{Path: path, Message: err.Error()}}
+		}
+		return s.validateValue(referenced, value, path)
+	}
+	violation := func(format string, args ...interface{}) Violation {
+		return Violation{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+	if schemaType, ok := schema["type"]; ok && !matchesType(schemaType, value) {
+		return []Violation{violation("expected %s, got %s", typeNames(schemaType), jsonType(value))}
+	}
+	violations := make([]Violation, 0)
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violations = append(violations, violation("value must be one of %v", enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, value) {
+		violations = append(violations, violation("value must be %v", constant))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		violations = append(violations, s.validateObject(schema, v, path)...)
+	case []interface{}:
+		violations = append(violations, s.validateArray(schema, v, path)...)
+	case string:
+		length := utf8.RuneCountInString(v)
+		if min, ok := toFloat(schema["minLength"]); ok && float64(length) < min {
+			violations = append(violations, violation("length must be >= %v", min))
+		}
+		if max, ok := toFloat(schema["maxLength"]); ok && float64(length) > max {
+			violations = append(violations, violation("length must be <= %v", max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !s.patterns[pattern].MatchString(v) {
+			violations = append(violations, violation("does not match pattern %q", pattern))
+		}
+		if format, ok := schema["format"].(string); ok && !matchesFormat(format, v) {
+			violations = append(violations, violation("is not a valid %s", format))
+		}
+	default:
+		if number, ok := toFloat(value); ok {
+			violations = append(violations, validateNumber(schema, number, violation)...)
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			violations = append(violations, s.validateValue(sub, value, path)...)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok && s.countMatches(anyOf, value, path) == 0 {
+		violations = append(violations, violation("does not match any of the allowed schemas"))
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok && s.countMatches(oneOf, value, path) != 1 {
+		violations = append(violations, violation("must match exactly one of the allowed schemas"))
+	}
+	if not, ok := schema["not"]; ok && len(s.validateValue(not, value, path)) == 0 {
+		violations = append(violations, violation("must not match the schema"))
+	}
+	return violations
+}
+
+func (s *jsonSchema) validateObject(schema map[string]interface{}, object map[string]interface{}, path string) []Violation {
+	violations := make([]Violation, 0)
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, ok := object[key]; !ok {
+				violations = append(violations, Violation{Path: path + "/" + escapePointer(key), Message: "is required"})
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "/" + escapePointer(key)
+		if property, ok := properties[key]; ok {
+			violations = append(violations, s.validateValue(property, object[key], childPath)...)
+			continue
+		}
+		if additional, ok := schema["additionalProperties"]; ok {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				violations = append(violations, Violation{Path: childPath, Message: "is not allowed"})
+				continue
+			}
+			violations = append(violations, s.validateValue(additional, object[key], childPath)...)
+		}
+	}
+	return violations
+}
+
+func (s *jsonSchema) validateArray(schema map[string]interface{}, array []interface{}, path string) []Violation {
+	violations := make([]Violation, 0)
+	if min, ok := toFloat(schema["minItems"]); ok && float64(len(array)) < min {
+		violations = append(violations, Violation{Path: path, Message: fmt.Sprintf("must have at least %v items", min)})
+	}
+	if max, ok := toFloat(schema["maxItems"]); ok && float64(len(array)) > max {
+		violations = append(violations, Violation{Path: path, Message: fmt.Sprintf("must have at most %v items", max)})
+	}
+	if unique, ok := schema["uniqueItems"].(bool); ok && unique {
+		for i := range array {
+			for j := 0; j < i; j++ {
+				if jsonEqual(array[i], array[j]) {
+					violations = append(violations, Violation{Path: fmt.Sprintf("%s/%d", path, i), Message: "is not unique"})
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range array {
+			violations = append(violations, s.validateValue(items, item, fmt.Sprintf("%s/%d", path, i))...)
+		}
+	}
+	return violations
+}
+
+func validateNumber(schema map[string]interface{}, number float64, violation func(string, ...interface{}) Violation) []Violation {
+	violations := make([]Violation, 0)
+	if min, ok := toFloat(schema["minimum"]); ok && number < min {
+		violations = append(violations, violation("must be >= %v", min))
+	}
+	if max, ok := toFloat(schema["maximum"]); ok && number > max {
+		violations = append(violations, violation("must be <= %v", max))
+	}
+	if min, ok := toFloat(schema["exclusiveMinimum"]); ok && number <= min {
+		violations = append(violations, violation("must be > %v", min))
+	}
+	if max, ok := toFloat(schema["exclusiveMaximum"]); ok && number >= max {
+		violations = append(violations, violation("must be < %v", max))
+	}
+	if multipleOf, ok := toFloat(schema["multipleOf"]); ok && multipleOf != 0 {
+		if quotient := number / multipleOf; quotient != math.Trunc(quotient) {
+			violations = append(violations, violation("must be a multiple of %v", multipleOf))
+		}
+	}
+	return violations
+}
+
+func (s *jsonSchema) countMatches(schemas []interface{}, value interface{}, path string) int {
+	matches := 0
+	for _, sub := range schemas {
+		if len(s.validateValue(sub, value, path)) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+func matchesType(schemaType interface{}, value interface{}) bool {
+	switch t := schemaType.(type) {
+	case string:
+		return matchesSingleType(t, value)
+	case []interface{}:
+		for _, single := range t {
+			if name, ok := single.(string); ok && matchesSingleType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesSingleType(schemaType string, value interface{}) bool {
+	actual := jsonType(value)
+	switch schemaType {
+	case "number":
+		return actual == "number" || actual == "integer"
+	case "integer":
+		return actual == "integer"
+	}
+	return actual == schemaType
+}
+
+func typeNames(schemaType interface{}) string {
+	if types, ok := schemaType.([]interface{}); ok {
+		names := make([]string, 0, len(types))
+		for _, t := range types {
+			names = append(names, fmt.Sprint(t))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(schemaType)
+}
+
+// jsonType returns the JSON schema type name of a value.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		if number, ok := toFloat(v); ok {
+			if number == math.Trunc(number) {
+				return "integer"
+			}
+			return "number"
+		}
+	}
+	return reflect.TypeOf(value).String()
+}
+
+func matchesFormat(format string, value string) bool {
+	switch format {
+	case "ipv4":
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+	case "ipv6":
+		ip := net.ParseIP(value)
+		return ip != nil && strings.Contains(value, ":")
+	}
+	return true
+}
+
+func jsonEqual(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
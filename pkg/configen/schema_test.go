@@ -0,0 +1,149 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_jsonSchema_validate(t *testing.T) {
+	schema := `{
+  "type": "object",
+  "required": ["description", "interfaces"],
+  "additionalProperties": false,
+  "properties": {
+    "description": {"type": "string", "minLength": 1, "maxLength": 10},
+    "mtu": {"type": "integer", "minimum": 1280, "maximum": 9000},
+    "role": {"enum": ["leaf", "spine"]},
+    "interfaces": {"type": "array", "minItems": 1, "items": {"$ref": "#/definitions/interface"}}
+  },
+  "definitions": {
+    "interface": {
+      "type": "object",
+      "required": ["name"],
+      "properties": {
+        "name": {"type": "string", "pattern": "^ifp_[0-9]+/[0-9]+/[0-9]+$"},
+        "ipv4": {"type": "string", "format": "ipv4"}
+      }
+    }
+  }
+}`
+	tests := []struct {
+		name       string
+		variables  string
+		violations []Violation
+	}{
+		{
+			name:      "valid variables",
+			variables: `{"description": "leaf", "mtu": 1500, "role": "leaf", "interfaces": [{"name": "ifp_0/0/1", "ipv4": "10.0.0.1"}]}`,
+		}, {
+			name:      "missing required variables",
+			variables: `{}`,
+			violations: []Violation{
+				{Path: "/description", Message: "is required"},
+				{Path: "/interfaces", Message: "is required"},
+			},
+		}, {
+			name:      "wrong types",
+			variables: `{"description": 1, "mtu": 1500.5, "interfaces": {}}`,
+			violations: []Violation{
+				{Path: "/description", Message: "expected string, got integer"},
+				{Path: "/interfaces", Message: "expected array, got object"},
+				{Path: "/mtu", Message: "expected integer, got number"},
+			},
+		}, {
+			name:      "nested violations",
+			variables: `{"description": "leaf", "interfaces": [{"name": "ifp_0/0/1"}, {"name": "eth0", "ipv4": "::1"}]}`,
+			violations: []Violation{
+				{Path: "/interfaces/1/ipv4", Message: "is not a valid ipv4"},
+				{Path: "/interfaces/1/name", Message: `does not match pattern "^ifp_[0-9]+/[0-9]+/[0-9]+$"`},
+			},
+		}, {
+			name:      "limits, enums and additional properties",
+			variables: `{"description": "a very long description", "mtu": 100, "role": "border", "interfaces": [], "a/b": 1}`,
+			violations: []Violation{
+				{Path: "/a~1b", Message: "is not allowed"},
+				{Path: "/description", Message: "length must be <= 10"},
+				{Path: "/interfaces", Message: "must have at least 1 items"},
+				{Path: "/mtu", Message: "must be >= 1280"},
+				{Path: "/role", Message: "value must be one of [leaf spine]"},
+			},
+		},
+	}
+	s, err := newJSONSchema([]byte(schema))
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var variables map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.variables), &variables))
+			err := s.validate(variables)
+			if tt.violations == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, errors.Is(err, ErrInvalidVariables))
+			var variablesError *VariablesError
+			require.True(t, errors.As(err, &variablesError))
+			if diff := cmp.Diff(tt.violations, variablesError.Violations); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_newJSONSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{name: "invalid json", schema: `{`},
+		{name: "invalid pattern", schema: `{"properties": {"name": {"pattern": "("}}}`},
+		{name: "unresolvable reference", schema: `{"properties": {"name": {"$ref": "#/definitions/name"}}}`},
+		{name: "remote reference", schema: `{"$ref": "http://example.com/schema.json"}`},
+		{name: "self reference", schema: `{"$ref": "#"}`},
+		{name: "circular definitions", schema: `{"definitions": {"a": {"$ref": "#/definitions/b"}, "b": {"allOf": [{"$ref": "#/definitions/a"}]}}, "properties": {"name": {"$ref": "#/definitions/a"}}}`},
+		{name: "circular not", schema: `{"definitions": {"a": {"not": {"$ref": "#/definitions/a"}}}, "$ref": "#/definitions/a"}`},
+		{name: "tuple items", schema: `{"properties": {"pair": {"items": [{"type": "string"}, {"type": "integer"}]}}}`},
+		{name: "pattern properties", schema: `{"patternProperties": {"^ifp": {"type": "object"}}}`},
+		{name: "conditional", schema: `{"properties": {"role": {"if": {"const": "leaf"}, "then": {"required": ["spine"]}}}}`},
+		{name: "nested unsupported keyword", schema: `{"definitions": {"port": {"contains": {"type": "integer"}}}}`},
+		{name: "unknown format", schema: `{"properties": {"mail": {"type": "string", "format": "email"}}}`},
+		{name: "schema is no object", schema: `{"properties": {"name": "string"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newJSONSchema([]byte(tt.schema))
+			require.True(t, errors.Is(err, ErrInvalidSchema), "error = %v", err)
+		})
+	}
+}
+
+func Test_jsonSchema_validate_recursive(t *testing.T) {
+	is := require.New(t)
+	s, err := newJSONSchema([]byte(`{"properties": {"name": {"type": "string"}, "children": {"type": "array", "items": {"$ref": "#"}}}}`))
+	is.NoError(err)
+	is.NoError(s.validate(map[string]interface{}{"name": "a", "children": []interface{}{map[string]interface{}{"name": "b"}}}))
+	err = s.validate(map[string]interface{}{"children": []interface{}{map[string]interface{}{"name": 1}}})
+	var variablesError *VariablesError
+	is.True(errors.As(err, &variablesError))
+	is.Equal([]Violation{{Path: "/children/0/name", Message: "expected string, got integer"}}, variablesError.Violations)
+}
@@ -0,0 +1,277 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"bytes"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TemplateStore provides the files of all templates.
+// The paths are slash separated and relative to the root of the store like the paths of an fs.FS,
+// e.g. sample/config.yaml is the config of the sample template.
+type TemplateStore interface {
+	fs.FS
+}
+
+// WritableTemplateStore is a template store which allows to replace and remove templates.
+type WritableTemplateStore interface {
+	TemplateStore
+	// ReplaceFolder replaces all files of the folder with the files, readers of the store see either the old or the new files.
+	// The files map the paths inside the folder to the file content. The result reports whether the folder was created.
+	ReplaceFolder(folder string, files map[string][]byte) (bool, error)
+	// RemoveFolder removes the folder with all its files.
+	RemoveFolder(folder string) error
+}
+
+// Ensure, that the stores do implement WritableTemplateStore.
+var (
+	_ WritableTemplateStore = &DirStore{}
+	_ WritableTemplateStore = &MemoryStore{}
+)
+
+// OpenStore opens the templates of the path, either a folder or a tar, tar.gz or zip archive.
+func OpenStore(templatePath string) (TemplateStore, error) {
+	info, err := os.Stat(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return NewDirStore(templatePath), nil
+	}
+	return NewArchiveStore(templatePath)
+}
+
+// DirStore is a template store of a folder of the local file system.
+type DirStore struct {
+	fs.FS
+	path string
+}
+
+// NewDirStore creates a template store of the folder.
+func NewDirStore(templatePath string) *DirStore {
+	return &DirStore{FS: os.DirFS(templatePath), path: templatePath}
+}
+
+// ReplaceFolder writes the files into a hidden staging folder and renames it to the folder afterwards.
+func (s *DirStore) ReplaceFolder(folder string, files map[string][]byte) (bool, error) {
+	stagingFolder, err := ioutil.TempDir(s.path, ".upload-")
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = os.RemoveAll(stagingFolder) }()
+	// The staging folder becomes the template folder, it gets the mode of the other folders instead of 0700.
+	if err := os.Chmod(stagingFolder, 0755); err != nil {
+		return false, err
+	}
+	for filePath, content := range files {
+		file := filepath.Join(stagingFolder, filepath.FromSlash(filePath))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return false, err
+		}
+		if err := ioutil.WriteFile(file, content, 0644); err != nil {
+			return false, err
+		}
+	}
+
+	targetFolder := filepath.Join(s.path, filepath.FromSlash(folder))
+	_, err = os.Stat(targetFolder)
+	created := os.IsNotExist(err)
+	trashFolder := ""
+	if !created {
+		if trashFolder, err = s.moveToTrash(targetFolder); err != nil {
+			return false, err
+		}
+	} else if err := os.MkdirAll(filepath.Dir(targetFolder), 0755); err != nil {
+		return false, err
+	}
+	if err := os.Rename(stagingFolder, targetFolder); err != nil {
+		if trashFolder != "" {
+			_ = os.Rename(filepath.Join(trashFolder, "template"), targetFolder)
+		}
+		return false, err
+	}
+	emptyTrash(trashFolder)
+	return created, nil
+}
+
+// RemoveFolder moves the folder into the trash, so that no half deleted folder is visible.
+func (s *DirStore) RemoveFolder(folder string) error {
+	trashFolder, err := s.moveToTrash(filepath.Join(s.path, filepath.FromSlash(folder)))
+	if err != nil {
+		return err
+	}
+	emptyTrash(trashFolder)
+	return nil
+}
+
+// moveToTrash moves the folder into a hidden trash folder of the template folder.
+// Renaming first ensures that no half deleted folder is visible.
+func (s *DirStore) moveToTrash(folder string) (string, error) {
+	trashFolder, err := ioutil.TempDir(s.path, ".delete-")
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(folder, filepath.Join(trashFolder, "template")); err != nil {
+		_ = os.RemoveAll(trashFolder)
+		return "", err
+	}
+	return trashFolder, nil
+}
+
+// emptyTrash deletes the trash folder in the background.
+func emptyTrash(trashFolder string) {
+	if trashFolder == "" {
+		return
+	}
+	go func() { _ = os.RemoveAll(trashFolder) }()
+}
+
+// MemoryStore is a template store which holds all files in memory, e.g. for tests.
+type MemoryStore struct {
+	mutex sync.RWMutex
+	files fstest.MapFS
+}
+
+// NewMemoryStore creates a template store of the files.
+// The files map the paths inside the store to the file content.
+func NewMemoryStore(files map[string][]byte) *MemoryStore {
+	s := &MemoryStore{files: make(fstest.MapFS, len(files))}
+	now := time.Now()
+	for filePath, content := range files {
+		s.files[filePath] = &fstest.MapFile{Data: content, Mode: 0644, ModTime: now}
+	}
+	return s
+}
+
+// Open opens the named file, folders are derived from the paths of the files.
+func (s *MemoryStore) Open(name string) (fs.File, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.files.Open(name)
+}
+
+// ReplaceFolder replaces all files of the folder with the files.
+func (s *MemoryStore) ReplaceFolder(folder string, files map[string][]byte) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	created := !s.removeFolder(folder)
+	now := time.Now()
+	for filePath, content := range files {
+		s.files[path.Join(folder, filePath)] = &fstest.MapFile{Data: content, Mode: 0644, ModTime: now}
+	}
+	return created, nil
+}
+
+// RemoveFolder removes all files of the folder.
+func (s *MemoryStore) RemoveFolder(folder string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.removeFolder(folder) {
+		return &fs.PathError{Op: "remove", Path: folder, Err: fs.ErrNotExist}
+	}
+	return nil
+}
+
+// removeFolder removes all files of the folder and reports whether there were any.
+func (s *MemoryStore) removeFolder(folder string) bool {
+	removed := false
+	for filePath := range s.files {
+		if strings.HasPrefix(filePath, folder+"/") {
+			delete(s.files, filePath)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// readOnlyStore hides the write methods of a template store.
+type readOnlyStore struct {
+	fs.FS
+}
+
+// NewFSStore creates a read only template store of a file system, e.g. of templates embedded into the binary.
+// Use fs.Sub if the templates are inside a folder of the file system.
+func NewFSStore(fsys fs.FS) TemplateStore {
+	return &readOnlyStore{FS: fsys}
+}
+
+// NewArchiveStore reads a tar, tar.gz or zip archive of templates into a read only template store.
+// The paths of the archive are the paths inside the store.
+func NewArchiveStore(archive string) (TemplateStore, error) {
+	content, err := ioutil.ReadFile(archive)
+	if err != nil {
+		return nil, err
+	}
+	var files map[string][]byte
+	switch {
+	case strings.HasSuffix(archive, ".zip"):
+		files, err = readZip(content, maxArchiveSize)
+	case strings.HasSuffix(archive, ".tar"):
+		files, err = readTar(bytes.NewReader(content), maxArchiveSize)
+	case strings.HasSuffix(archive, ".tar.gz"), strings.HasSuffix(archive, ".tgz"):
+		files, err = ReadTarGz(bytes.NewReader(content))
+	default:
+		return nil, errors.Errorf("unknown archive type of %s, expected a tar, tar.gz or zip archive", archive)
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, archive)
+	}
+	storeFiles := make(map[string][]byte, len(files))
+	for filePath, fileContent := range files {
+		cleaned, err := cleanFilePath(filePath)
+		if err != nil {
+			return nil, errors.WithMessage(err, archive)
+		}
+		storeFiles[cleaned] = fileContent
+	}
+	return NewFSStore(NewMemoryStore(storeFiles)), nil
+}
+
+// overlayStore shows the files of a folder instead of the folder of the underlying store.
+// It is used to validate a template before it replaces the folder.
+type overlayStore struct {
+	TemplateStore
+	folder string
+	files  fstest.MapFS
+}
+
+// newOverlayStore creates a store which shows the files in the folder instead of the files of the store.
+func newOverlayStore(store TemplateStore, folder string, files map[string][]byte) *overlayStore {
+	s := &overlayStore{TemplateStore: store, folder: folder, files: make(fstest.MapFS, len(files))}
+	for filePath, content := range files {
+		s.files[path.Join(folder, filePath)] = &fstest.MapFile{Data: content, Mode: 0644}
+	}
+	return s
+}
+
+// Open opens the files of the folder from the overlay and all other files from the underlying store.
+func (s *overlayStore) Open(name string) (fs.File, error) {
+	if name == s.folder || strings.HasPrefix(name, s.folder+"/") {
+		return s.files.Open(name)
+	}
+	return s.TemplateStore.Open(name)
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package configen
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+var storeTemplateFiles = map[string][]byte{
+	"t/config.yaml":          []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\ninclude_pattern: \"includes/*.gotext\"\n"),
+	"t/main.gotext":          []byte("Hi {{.name}}{{template \"footer.gotext\"}}"),
+	"includes/footer.gotext": []byte("!"),
+}
+
+func TestMemoryStore(t *testing.T) {
+	is := require.New(t)
+	r := NewStoreRepository(NewMemoryStore(storeTemplateFiles))
+	got, _, err := r.GenerateFile(context.Background(), "t", map[string]interface{}{"name": "Chris"})
+	is.NoError(err)
+	is.Equal("Hi Chris!", string(got))
+	templates, err := r.Templates()
+	is.NoError(err)
+	is.Len(templates, 1)
+
+	config := storeTemplateFiles["t/config.yaml"]
+	created, err := r.PutTemplate("t", map[string][]byte{"config.yaml": config, "main.gotext": []byte("Bye {{.name}}{{template \"footer.gotext\"}}")})
+	is.NoError(err)
+	is.False(created)
+	got, _, err = r.GenerateFile(context.Background(), "t", map[string]interface{}{"name": "Chris"})
+	is.NoError(err)
+	is.Equal("Bye Chris!", string(got))
+
+	// Invalid uploads don't replace the template.
+	_, err = r.PutTemplate("t", map[string][]byte{"config.yaml": config, "main.gotext": []byte("{{")})
+	is.True(errors.Is(err, ErrInvalidTemplate))
+	created, err = r.PutTemplate("u", map[string][]byte{"config.yaml": config, "main.gotext": []byte("u")})
+	is.NoError(err)
+	is.True(created)
+
+	is.NoError(r.DeleteTemplate("t"))
+	_, _, err = r.GenerateFile(context.Background(), "t", nil)
+	is.True(errors.Is(err, ErrTemplateConfigNotFound))
+	got, _, err = r.GenerateFile(context.Background(), "u", nil)
+	is.NoError(err)
+	is.Equal("u", string(got))
+}
+
+func TestNewFSStore(t *testing.T) {
+	is := require.New(t)
+	files := make(fstest.MapFS)
+	for filePath, content := range storeTemplateFiles {
+		files[filePath] = &fstest.MapFile{Data: content}
+	}
+	r := NewStoreRepository(NewFSStore(files))
+	got, _, err := r.GenerateFile(context.Background(), "t", map[string]interface{}{"name": "Chris"})
+	is.NoError(err)
+	is.Equal("Hi Chris!", string(got))
+
+	_, err = r.PutTemplate("t", map[string][]byte{"config.yaml": storeTemplateFiles["t/config.yaml"]})
+	is.True(errors.Is(err, ErrReadOnlyStore))
+	is.True(errors.Is(r.DeleteTemplate("t"), ErrReadOnlyStore))
+}
+
+func TestNewArchiveStore(t *testing.T) {
+	tests := []struct {
+		name      string
+		archive   string
+		write     func(is *require.Assertions, files map[string][]byte) []byte
+		files     map[string][]byte
+		wantedErr error
+	}{
+		{
+			name:    "tar",
+			archive: "templates.tar",
+			write:   writeTar,
+			files:   storeTemplateFiles,
+		}, {
+			name:    "tar.gz",
+			archive: "templates.tar.gz",
+			write:   writeTarGz,
+			files:   storeTemplateFiles,
+		}, {
+			name:    "zip",
+			archive: "templates.zip",
+			write:   writeZip,
+			files:   storeTemplateFiles,
+		}, {
+			name:      "file outside of the store",
+			archive:   "templates.tar",
+			write:     writeTar,
+			files:     map[string][]byte{"../escape.gotext": []byte("")},
+			wantedErr: ErrInvalidTemplate,
+		}, {
+			name:      "no archive",
+			archive:   "templates.zip",
+			write:     func(is *require.Assertions, files map[string][]byte) []byte { return []byte("no archive") },
+			wantedErr: ErrInvalidTemplate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			folder, err := ioutil.TempDir("", "archive")
+			is.NoError(err)
+			defer func() { _ = os.RemoveAll(folder) }()
+			archive := filepath.Join(folder, tt.archive)
+			is.NoError(ioutil.WriteFile(archive, tt.write(is, tt.files), 0644))
+
+			store, err := OpenStore(archive)
+			if tt.wantedErr != nil {
+				if !errors.Is(err, tt.wantedErr) {
+					t.Errorf("OpenStore() error = %v, wantedErr %v", err, tt.wantedErr)
+				}
+				return
+			}
+			is.NoError(err)
+			r := NewStoreRepository(store)
+			got, _, err := r.GenerateFile(context.Background(), "t", map[string]interface{}{"name": "Chris"})
+			is.NoError(err)
+			is.Equal("Hi Chris!", string(got))
+			_, err = r.PutTemplate("t", tt.files)
+			is.True(errors.Is(err, ErrReadOnlyStore))
+		})
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	is := require.New(t)
+	store, err := OpenStore("testdata/templates")
+	is.NoError(err)
+	is.IsType(&DirStore{}, store)
+
+	_, err = OpenStore("testdata/missing")
+	is.True(errors.Is(err, os.ErrNotExist))
+	_, err = OpenStore("testdata/templates/t1/config.yaml")
+	is.Error(err)
+}
+
+func writeTar(is *require.Assertions, files map[string][]byte) []byte {
+	var buffer bytes.Buffer
+	tarWriter := tar.NewWriter(&buffer)
+	for filePath, content := range files {
+		is.NoError(tarWriter.WriteHeader(&tar.Header{Name: filePath, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := tarWriter.Write(content)
+		is.NoError(err)
+	}
+	is.NoError(tarWriter.Close())
+	return buffer.Bytes()
+}
+
+func writeTarGz(is *require.Assertions, files map[string][]byte) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	_, err := gzipWriter.Write(writeTar(is, files))
+	is.NoError(err)
+	is.NoError(gzipWriter.Close())
+	return buffer.Bytes()
+}
+
+func writeZip(is *require.Assertions, files map[string][]byte) []byte {
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	for filePath, content := range files {
+		writer, err := zipWriter.Create(filePath)
+		is.NoError(err)
+		_, err = writer.Write(content)
+		is.NoError(err)
+	}
+	is.NoError(zipWriter.Close())
+	return buffer.Bytes()
+}
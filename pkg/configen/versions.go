@@ -0,0 +1,134 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+
+	sv2 "github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+)
+
+// ResolveTemplate returns the template folder and the version of the template matching the version constraint.
+// Versioned templates are stored as <name>/<semver>, without constraint the highest version is used.
+// Templates without versions are returned as they are, the version is empty in that case.
+func (r *Repository) ResolveTemplate(templateName string, constraint string) (string, string, error) {
+	if !isValidTemplateName(templateName) {
+		return "", "", errors.WithMessage(ErrInvalidTemplateName, templateName)
+	}
+	var versionConstraint *sv2.Constraints
+	if len(constraint) > 0 {
+		c, err := sv2.NewConstraint(constraint)
+		if err != nil {
+			return "", "", errors.WithMessage(ErrInvalidVersionConstraint, err.Error())
+		}
+		versionConstraint = c
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	versions, err := templateVersions(r.store, templateName)
+	if err != nil {
+		return "", "", err
+	}
+	if len(versions) == 0 {
+		if versionConstraint != nil && isTemplateFolder(r.store, templateName) {
+			return "", "", errors.WithMessagef(ErrTemplateVersionNotFound, "template %s has no versions", templateName)
+		}
+		return templateName, "", nil
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versionConstraint == nil || versionConstraint.Check(versions[i]) {
+			return templateName + "/" + versions[i].Original(), versions[i].Original(), nil
+		}
+	}
+	return "", "", errors.WithMessagef(ErrTemplateVersionNotFound, "template %s %s", templateName, constraint)
+}
+
+// TemplateVersionFolder returns the template folder of a version of the template.
+func TemplateVersionFolder(templateName string, version string) (string, error) {
+	if _, err := sv2.NewVersion(version); err != nil {
+		return "", errors.WithMessagef(ErrInvalidTemplateName, "version %s: %v", version, err)
+	}
+	return templateName + "/" + version, nil
+}
+
+// templateVersions returns the versions of the template in ascending order.
+// Templates with a config.yaml in the template folder itself have no versions.
+func templateVersions(store TemplateStore, templateName string) ([]*sv2.Version, error) {
+	if isTemplateFolder(store, templateName) {
+		return nil, nil
+	}
+	entries, err := fs.ReadDir(store, templateName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*sv2.Version, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !isTemplateFolder(store, path.Join(templateName, entry.Name())) {
+			continue
+		}
+		if version, err := sv2.NewVersion(entry.Name()); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	sort.Sort(sv2.Collection(versions))
+	return versions, nil
+}
+
+// latestTemplateFolder returns the folder of the highest version of a versioned template.
+// Templates without versions are returned as they are.
+func latestTemplateFolder(store TemplateStore, templateName string) (string, error) {
+	versions, err := templateVersions(store, templateName)
+	if err != nil || len(versions) == 0 {
+		return templateName, err
+	}
+	return templateName + "/" + versions[len(versions)-1].Original(), nil
+}
+
+// splitVersion splits the version from the name of a versioned template folder.
+func splitVersion(store TemplateStore, templateFolder string) (string, string) {
+	parent, version := path.Split(templateFolder)
+	if len(parent) == 0 || isTemplateFolder(store, path.Clean(parent)) {
+		return templateFolder, ""
+	}
+	if _, err := sv2.NewVersion(version); err != nil {
+		return templateFolder, ""
+	}
+	return path.Clean(parent), version
+}
+
+// versionLess compares two template versions, versions which can't be parsed are compared as strings.
+func versionLess(a string, b string) bool {
+	va, errA := sv2.NewVersion(a)
+	vb, errB := sv2.NewVersion(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return va.LessThan(vb)
+}
+
+// isTemplateFolder checks whether the folder contains a config.yaml.
+func isTemplateFolder(store TemplateStore, folder string) bool {
+	_, err := fs.Stat(store, path.Join(folder, "config.yaml"))
+	return err == nil
+}
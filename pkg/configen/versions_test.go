@@ -0,0 +1,117 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package configen
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_ResolveTemplate(t *testing.T) {
+	tests := []struct {
+		name         string
+		templateName string
+		constraint   string
+		wantFolder   string
+		wantVersion  string
+		wantedErr    error
+	}{
+		{
+			name:         "highest version",
+			templateName: "v1",
+			wantFolder:   "v1/1.10.0",
+			wantVersion:  "1.10.0",
+		}, {
+			name:         "tilde constraint",
+			templateName: "v1",
+			constraint:   "~1.2",
+			wantFolder:   "v1/1.2.0",
+			wantVersion:  "1.2.0",
+		}, {
+			name:         "range constraint",
+			templateName: "v1",
+			constraint:   ">= 1.0, < 1.2",
+			wantFolder:   "v1/1.0.0",
+			wantVersion:  "1.0.0",
+		}, {
+			name:         "no matching version",
+			templateName: "v1",
+			constraint:   ">= 2",
+			wantedErr:    ErrTemplateVersionNotFound,
+		}, {
+			name:         "invalid constraint",
+			templateName: "v1",
+			constraint:   "latest",
+			wantedErr:    ErrInvalidVersionConstraint,
+		}, {
+			name:         "unversioned template",
+			templateName: "g2",
+			wantFolder:   "g2",
+		}, {
+			name:         "unversioned template with constraint",
+			templateName: "g2",
+			constraint:   "1.0.0",
+			wantedErr:    ErrTemplateVersionNotFound,
+		}, {
+			name:         "invalid template name",
+			templateName: "../v1",
+			wantedErr:    ErrInvalidTemplateName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := require.New(t)
+			r := NewRepository("testdata/templates")
+			folder, version, err := r.ResolveTemplate(tt.templateName, tt.constraint)
+			if tt.wantedErr != nil {
+				is.True(errors.Is(err, tt.wantedErr), "error = %v, wantedErr %v", err, tt.wantedErr)
+				return
+			}
+			is.NoError(err)
+			is.Equal(tt.wantFolder, folder)
+			is.Equal(tt.wantVersion, version)
+		})
+	}
+}
+
+func TestRepository_GenerateFileVersioned(t *testing.T) {
+	is := require.New(t)
+	r := NewRepository("testdata/templates")
+	folder, _, err := r.ResolveTemplate("v1", "~1.2")
+	is.NoError(err)
+	got, _, err := r.GenerateFile(context.Background(), folder, nil)
+	is.NoError(err)
+	is.Equal("version 1.2.0", string(got))
+}
+
+func TestRepository_GenerateFileExtendsVersioned(t *testing.T) {
+	is := require.New(t)
+	parent := []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n")
+	r := NewStoreRepository(NewMemoryStore(map[string][]byte{
+		"p/1.0.0/config.yaml": parent,
+		"p/1.0.0/main.gotext": []byte("v1"),
+		"p/2.0.0/config.yaml": parent,
+		"p/2.0.0/main.gotext": []byte("v2"),
+		"c/config.yaml":       []byte("extends: p\n"),
+	}))
+	got, _, err := r.GenerateFile(context.Background(), "c", nil)
+	is.NoError(err)
+	is.Equal("v2", string(got))
+}
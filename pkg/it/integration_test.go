@@ -17,7 +17,7 @@
 package configen
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -31,7 +31,6 @@ import (
 	isTest "github.com/matryer/is"
 
 	"github.com/google/go-cmp/cmp"
-	"github.com/yosuke-furukawa/json5/encoding/json5"
 )
 
 // TestRepository_IntegrationTest are all tests for the sample templates folder.
@@ -62,7 +61,7 @@ func TestRepository_IntegrationTest(t *testing.T) {
 				return
 			}
 
-			got, format, err := r.GenerateFile(tt.template, variables)
+			got, format, err := r.GenerateFile(context.Background(), tt.template, variables)
 			if err != nil {
 				log.Error().Err(err).Msg("error in generation")
 				return
@@ -75,36 +74,9 @@ func TestRepository_IntegrationTest(t *testing.T) {
 			want, err := ioutil.ReadFile(resultFile)
 			is.NoErr(err)
 
-			switch format {
-			case "json":
-				if diff := cmp.Diff(transformToJSONObject(want), transformToJSONObject(got)); diff != "" {
-					t.Errorf("mismatch (-want +got):\n%s", diff)
-				}
-			case "json5":
-				if diff := cmp.Diff(transformToJSON5Object(want), transformToJSON5Object(got)); diff != "" {
-					t.Errorf("mismatch (-want +got):\n%s", diff)
-				}
-			case "txt":
-				fallthrough
-			default:
-				if diff := cmp.Diff(string(want), string(got)); diff != "" {
-					t.Errorf("mismatch (-want +got):\n%s", diff)
-				}
+			if diff := cmp.Diff(configen.NormalizeOutput(format, want), configen.NormalizeOutput(format, got)); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
 			}
 		})
 	}
 }
-func transformToJSONObject(value []byte) interface{} {
-	var v interface{}
-	if err := json.Unmarshal(value, &v); err != nil {
-		return fmt.Sprintf("not parseable (%s)", value) // use unparseable input as the output
-	}
-	return v
-}
-func transformToJSON5Object(value []byte) interface{} {
-	var v interface{}
-	if err := json5.Unmarshal(value, &v); err != nil {
-		return value // use unparseable input as the output
-	}
-	return v
-}
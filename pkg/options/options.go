@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 var (
@@ -31,6 +32,18 @@ var (
 type Options struct {
 	HTTPAddress  string `json:"http_address"`
 	TemplatePath string `json:"template_path"`
+	// Sandbox blocks the template functions giving access to the server (e.g. env and expandenv)
+	Sandbox bool `json:"sandbox"`
+	// RenderTimeout limits the time of a single template execution (e.g. "30s")
+	RenderTimeout string `json:"render_timeout"`
+	// MaxOutputSize limits the size of a single generated output in bytes
+	MaxOutputSize int64 `json:"max_output_size"`
+}
+
+// RenderTimeoutDuration returns the render timeout, 0 if no render timeout is set
+func (o *Options) RenderTimeoutDuration() time.Duration {
+	timeout, _ := time.ParseDuration(o.RenderTimeout)
+	return timeout
 }
 
 // Validate the options
@@ -44,6 +57,15 @@ func (o *Options) Validate() error {
 		msgs = append(msgs, "missing setting: template_path")
 	}
 
+	if len(o.RenderTimeout) > 0 {
+		if timeout, err := time.ParseDuration(o.RenderTimeout); err != nil || timeout <= 0 {
+			msgs = append(msgs, "invalid setting: render_timeout")
+		}
+	}
+	if o.MaxOutputSize < 0 {
+		msgs = append(msgs, "invalid setting: max_output_size")
+	}
+
 	if len(msgs) != 0 {
 		return fmt.Errorf("%w\ndetail:\n%s", ErrInvalidConfiguration,
 			strings.Join(msgs, "\n  "))
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	isTest "github.com/matryer/is"
 )
@@ -72,3 +73,27 @@ func TestNewOptions(t *testing.T) {
 		is.Equal(expected, err.Error())
 	}
 }
+
+func TestLimitOptions(t *testing.T) {
+	expected := errorMsg([]string{
+		"invalid setting: render_timeout",
+		"invalid setting: max_output_size",
+	})
+	is := isTest.New(t)
+	o := &Options{
+		HTTPAddress:   "http://localhost:29092",
+		TemplatePath:  "./templates",
+		RenderTimeout: "soon",
+		MaxOutputSize: -1,
+	}
+	err := o.Validate()
+	is.True(err != nil)
+	if err != nil {
+		is.Equal(expected, err.Error())
+	}
+
+	o.RenderTimeout = "30s"
+	o.MaxOutputSize = 1 << 20
+	is.NoErr(o.Validate())
+	is.Equal(30*time.Second, o.RenderTimeoutDuration())
+}
@@ -209,3 +209,10 @@ func (r *responseStats) Hijack() (_ net.Conn, _ *bufio.ReadWriter, err error) {
 	}
 	return nil, nil, errors.New("underlying ResponseWriter does not support hijacking")
 }
+
+// Flush ...
+func (r *responseStats) Flush() {
+	if f, ok := r.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
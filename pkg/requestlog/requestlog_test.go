@@ -78,6 +78,29 @@ func TestHandler(t *testing.T) {
 	}
 }
 
+func TestHandlerFlush(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost/foo", nil)
+	if err != nil {
+		t.Fatal("NewRequest:", err)
+	}
+	flushed := false
+	_, err = roundTrip(r, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return
+		}
+		_, _ = io.WriteString(w, "line\n")
+		flusher.Flush()
+		flushed = true
+	}))
+	if err != nil {
+		t.Fatal("Could not get entry:", err)
+	}
+	if !flushed {
+		t.Error("ResponseWriter does not implement http.Flusher")
+	}
+}
+
 func roundTrip(r *http.Request, h http.Handler) (*Entry, error) {
 	capture := new(captureLogger)
 	handler := NewHandler(capture, h)
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/configen"
+)
+
+const (
+	contentTypeTar       = "application/x-tar"
+	contentTypeZip       = "application/zip"
+	contentTypeMultipart = "multipart/mixed"
+)
+
+// contentTypeOfFormat returns the Content-Type of a generated file.
+func contentTypeOfFormat(format string) string {
+	switch format {
+	case "json", "json5":
+		return "application/json"
+	case "yaml", "yml":
+		return "application/yaml"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// bundleContentType selects the bundle format by the Accept header, tar is the default.
+func bundleContentType(accept string) string {
+	switch {
+	case strings.Contains(accept, contentTypeZip):
+		return contentTypeZip
+	case strings.Contains(accept, contentTypeMultipart):
+		return contentTypeMultipart
+	}
+	return contentTypeTar
+}
+
+// writeBundle writes the bundle in the format requested by the Accept header.
+func writeBundle(w http.ResponseWriter, req *http.Request, bundle *configen.Bundle) {
+	var body bytes.Buffer
+	contentType := bundleContentType(req.Header.Get("Accept"))
+	var err error
+	switch contentType {
+	case contentTypeZip:
+		err = writeBundleZip(&body, bundle)
+	case contentTypeMultipart:
+		contentType, err = writeBundleMultipart(&body, bundle)
+	default:
+		err = writeBundleTar(&body, bundle)
+	}
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body.Bytes())
+}
+
+// writeBundleTar writes all files of the bundle as tar archive.
+func writeBundleTar(w io.Writer, bundle *configen.Bundle) error {
+	tarWriter := tar.NewWriter(w)
+	now := time.Now()
+	for _, file := range bundle.Files {
+		header := &tar.Header{
+			Name:    file.Name,
+			Mode:    0644,
+			Size:    int64(len(file.Content)),
+			ModTime: now,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tarWriter.Write(file.Content); err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+// writeBundleZip writes all files of the bundle as zip archive.
+func writeBundleZip(w io.Writer, bundle *configen.Bundle) error {
+	zipWriter := zip.NewWriter(w)
+	for _, file := range bundle.Files {
+		fileWriter, err := zipWriter.Create(file.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := fileWriter.Write(file.Content); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// writeBundleMultipart writes each file of the bundle as part of a multipart message and returns the Content-Type.
+func writeBundleMultipart(w io.Writer, bundle *configen.Bundle) (string, error) {
+	multipartWriter := multipart.NewWriter(w)
+	for _, file := range bundle.Files {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", contentTypeOfFormat(file.Format))
+		// The file name is escaped, output names may contain quotes.
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+		partWriter, err := multipartWriter.CreatePart(header)
+		if err != nil {
+			return "", err
+		}
+		if _, err := partWriter.Write(file.Content); err != nil {
+			return "", err
+		}
+	}
+	if err := multipartWriter.Close(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s; boundary=%s", contentTypeMultipart, multipartWriter.Boundary()), nil
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/configen"
+)
+
+var testBundle = &configen.Bundle{
+	MultiFile: true,
+	Files: []*configen.BundleFile{
+		{Name: "config.json", Format: "json", Content: []byte(`{"a":1}`)},
+		{Name: `a"b.txt`, Format: "text", Content: []byte("quoted")},
+	},
+}
+
+func Test_writeBundleMultipart(t *testing.T) {
+	is := require.New(t)
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Accept", "multipart/mixed")
+	w := httptest.NewRecorder()
+	writeBundle(w, req, testBundle)
+	is.Equal(http.StatusOK, w.Code)
+
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	is.NoError(err)
+	is.Equal("multipart/mixed", mediaType)
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	for _, file := range testBundle.Files {
+		part, err := reader.NextPart()
+		is.NoError(err)
+		is.Equal(file.Name, part.FileName())
+		is.Equal(contentTypeOfFormat(file.Format), part.Header.Get("Content-Type"))
+		content, err := ioutil.ReadAll(part)
+		is.NoError(err)
+		is.Equal(string(file.Content), string(content))
+	}
+	_, err = reader.NextPart()
+	is.Equal(io.EOF, err)
+}
+
+func Test_writeBundleTar(t *testing.T) {
+	is := require.New(t)
+	w := httptest.NewRecorder()
+	writeBundle(w, httptest.NewRequest(http.MethodPost, "/", nil), testBundle)
+	is.Equal(http.StatusOK, w.Code)
+	is.Equal("application/x-tar", w.Header().Get("Content-Type"))
+
+	reader := tar.NewReader(w.Body)
+	for _, file := range testBundle.Files {
+		header, err := reader.Next()
+		is.NoError(err)
+		is.Equal(file.Name, header.Name)
+		content, err := ioutil.ReadAll(reader)
+		is.NoError(err)
+		is.Equal(string(file.Content), string(content))
+	}
+	_, err := reader.Next()
+	is.Equal(io.EOF, err)
+}
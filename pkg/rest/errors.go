@@ -0,0 +1,162 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/configen"
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+)
+
+// VariablesErrorMessage is returned if the variables don't match the variables schema of the template
+type VariablesErrorMessage struct {
+	Message    string               `json:"message"`
+	Violations []configen.Violation `json:"violations"`
+}
+
+// OutputErrorMessage is returned if the generated output doesn't match the declared output format
+type OutputErrorMessage struct {
+	Message string `json:"message"`
+	Format  string `json:"format"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// MissingKeyErrorMessage is returned if a template accesses a variable which is not set
+type MissingKeyErrorMessage struct {
+	Message    string `json:"message"`
+	Key        string `json:"key"`
+	Expression string `json:"expression,omitempty"`
+	Template   string `json:"template"`
+	Line       int    `json:"line,omitempty"`
+	Column     int    `json:"column,omitempty"`
+	Snippet    string `json:"snippet,omitempty"`
+}
+
+// RenderErrorMessage is returned if a template can't be parsed or executed
+type RenderErrorMessage struct {
+	Message  string `json:"message"`
+	Template string `json:"template"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Action   string `json:"action,omitempty"`
+	Error    string `json:"error"`
+	Snippet  string `json:"snippet,omitempty"`
+}
+
+// errorResponseStatus maps the errors of the template engine to http status codes.
+func errorResponseStatus(err error, defaultStatus int) int {
+	switch {
+	case errors.Is(err, configen.ErrTemplateConfigNotFound),
+		errors.Is(err, configen.ErrTemplateVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, configen.ErrInvalidTemplateName),
+		errors.Is(err, configen.ErrInvalidVersionConstraint),
+		errors.Is(err, configen.ErrInvalidFeatureVersion):
+		return http.StatusBadRequest
+	case errors.Is(err, configen.ErrInvalidTemplate),
+		errors.Is(err, configen.ErrInvalidVariables),
+		errors.Is(err, configen.ErrInvalidOutput),
+		errors.Is(err, configen.ErrMissingKey),
+		errors.Is(err, configen.ErrFunctionNotAllowed),
+		errors.Is(err, configen.ErrRenderTimeout),
+		errors.Is(err, configen.ErrRenderCanceled),
+		errors.Is(err, configen.ErrMultipleOutputs),
+		errors.Is(err, configen.ErrOutputTooLarge),
+		errors.Is(err, configen.ErrInvalidFeatures),
+		errors.Is(err, configen.ErrFeatureNotFound),
+		errors.Is(err, configen.ErrInvalidData),
+		errors.Is(err, configen.ErrDataNotFound),
+		errors.Is(err, configen.ErrRender),
+		errors.Is(err, configen.ErrEngineNotFound),
+		errors.Is(err, configen.ErrPostProcessorNotFound),
+		errors.Is(err, configen.ErrInvalidPostProcessorArgs),
+		errors.Is(err, configen.ErrInvalidSchema),
+		errors.Is(err, configen.ErrInvalidOutputs),
+		errors.Is(err, configen.ErrInvalidExtends),
+		errors.Is(err, configen.ErrInvalidDefaults),
+		errors.Is(err, configen.ErrInvalidMissingKey),
+		errors.Is(err, configen.ErrInvalidDelimiters),
+		errors.Is(err, configen.ErrInvalidLimits):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, configen.ErrTemplateConflict):
+		return http.StatusConflict
+	case errors.Is(err, configen.ErrReadOnlyStore):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, configen.ErrNotSupported):
+		return http.StatusNotImplemented
+	}
+	return defaultStatus
+}
+
+// errorResponse maps an error of the template engine to the http status code and the response body.
+// The default status is used for errors without a particular status code.
+func errorResponse(err error, defaultStatus int) (int, interface{}) {
+	status := errorResponseStatus(err, defaultStatus)
+	var variablesError *configen.VariablesError
+	if errors.As(err, &variablesError) {
+		return status, &VariablesErrorMessage{
+			Message:    fmt.Sprintf("error %v", err),
+			Violations: variablesError.Violations,
+		}
+	}
+	var outputError *configen.OutputError
+	if errors.As(err, &outputError) {
+		return status, &OutputErrorMessage{
+			Message: fmt.Sprintf("error %v", err),
+			Format:  outputError.Format,
+			Line:    outputError.Line,
+			Column:  outputError.Column,
+			Snippet: outputError.Snippet,
+		}
+	}
+	var missingKeyError *configen.MissingKeyError
+	if errors.As(err, &missingKeyError) {
+		return status, &MissingKeyErrorMessage{
+			Message:    fmt.Sprintf("error %v", err),
+			Key:        missingKeyError.Key,
+			Expression: missingKeyError.Expression,
+			Template:   missingKeyError.Template,
+			Line:       missingKeyError.Line,
+			Column:     missingKeyError.Column,
+			Snippet:    missingKeyError.Snippet,
+		}
+	}
+	var renderError *configen.RenderError
+	if errors.As(err, &renderError) {
+		return status, &RenderErrorMessage{
+			Message:  fmt.Sprintf("error %v", err),
+			Template: renderError.Template,
+			Line:     renderError.Line,
+			Column:   renderError.Column,
+			Action:   renderError.Action,
+			Error:    renderError.Message,
+			Snippet:  renderError.Snippet,
+		}
+	}
+	return status, &util.Message{Message: fmt.Sprintf("error %v", err)}
+}
+
+// writeError writes the error of the template engine as response.
+func writeError(w http.ResponseWriter, err error, defaultStatus int) {
+	status, body := errorResponse(err, defaultStatus)
+	util.WriteAsJSON(w, status, body)
+}
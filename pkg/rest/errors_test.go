@@ -0,0 +1,73 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/configen"
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+)
+
+func Test_errorResponseStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "template not found", err: configen.ErrTemplateConfigNotFound, want: http.StatusNotFound},
+		{name: "version not found", err: errors.WithMessage(configen.ErrTemplateVersionNotFound, "v1"), want: http.StatusNotFound},
+		{name: "invalid version constraint", err: errors.WithMessage(configen.ErrInvalidVersionConstraint, "v1"), want: http.StatusBadRequest},
+		{name: "invalid template", err: errors.WithMessage(configen.ErrInvalidTemplate, "t"), want: http.StatusUnprocessableEntity},
+		{name: "render timeout", err: configen.ErrRenderTimeout, want: http.StatusUnprocessableEntity},
+		{name: "render canceled", err: errors.WithMessage(configen.ErrRenderCanceled, "current"), want: http.StatusUnprocessableEntity},
+		{name: "multiple outputs", err: errors.WithMessage(configen.ErrMultipleOutputs, "g8"), want: http.StatusUnprocessableEntity},
+		{name: "output too large", err: configen.ErrOutputTooLarge, want: http.StatusUnprocessableEntity},
+		{name: "conflict", err: configen.ErrTemplateConflict, want: http.StatusConflict},
+		{name: "read only store", err: configen.ErrReadOnlyStore, want: http.StatusMethodNotAllowed},
+		{name: "not supported", err: configen.ErrNotSupported, want: http.StatusNotImplemented},
+		{name: "other error", err: errors.New("disk full"), want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, errorResponseStatus(tt.err, http.StatusInternalServerError))
+		})
+	}
+}
+
+func Test_errorResponse(t *testing.T) {
+	is := require.New(t)
+	violations := []configen.Violation{{Path: "/name", Message: "is required"}}
+	status, body := errorResponse(&configen.VariablesError{Violations: violations}, http.StatusInternalServerError)
+	is.Equal(http.StatusUnprocessableEntity, status)
+	is.Equal(&VariablesErrorMessage{Message: "error invalid variables: /name: is required", Violations: violations}, body)
+
+	status, body = errorResponse(errors.WithMessage(&configen.MissingKeyError{Key: "site", Template: "main.gotext", Line: 1, Column: 4}, "t"), http.StatusInternalServerError)
+	is.Equal(http.StatusUnprocessableEntity, status)
+	message, ok := body.(*MissingKeyErrorMessage)
+	is.True(ok)
+	is.Equal("site", message.Key)
+	is.Equal("main.gotext", message.Template)
+
+	status, body = errorResponse(errors.New("disk full"), http.StatusBadRequest)
+	is.Equal(http.StatusBadRequest, status)
+	is.Equal(&util.Message{Message: "error disk full"}, body)
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/job"
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// batchConcurrency is the number of items of a batch which are generated in parallel.
+const batchConcurrency = 8
+
+// contentTypeNDJSON is the content type of the streamed batch results, one JSON object per line.
+const contentTypeNDJSON = "application/x-ndjson"
+
+// @Summary generate configuration files in a batch
+// @Description Generates a configuration file for each item of the batch, the items are generated in parallel.
+// @Description The results are streamed as newline delimited JSON in the order the items are done, one line for each item.
+// @Description **Characteristics:**
+// @Description * Operation: **synchronous**
+// @Tags template-engine
+// @Accept  json
+// @Produce  application/x-ndjson
+// @Param body body BatchRequest true "body"
+// @Success 200 {object} BatchItemResult "one result line for each item"
+// @Failure 400 {object} util.Message
+// @Router /template-engine/api/v1/_generatebatch [POST]
+func (app *Application) generateBatchSync(w http.ResponseWriter, req *http.Request) {
+	requestBody, ok := readBatchRequest(w, req)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	app.generateBatch(req.Context(), requestBody.Items, func(result *BatchItemResult) {
+		// Write errors mean that the client is gone, the context stops the remaining items then.
+		_ = encoder.Encode(result)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+}
+
+// @Summary generate configuration files in a batch
+// @Description Generates a configuration file for each item of the batch, the items are generated in parallel.
+// @Description The job result contains the result of each item.
+// @Description **Characteristics:**
+// @Description * Operation: **asynchronous**
+// @Tags template-engine
+// @Accept  json
+// @Produce  json
+// @Param response_uri header string false "callback response uri"
+// @Param body body BatchRequest true "body"
+// @Header 202 {string} Location "Location to get the job result"
+// @Success 202 "Accepted"
+// @Failure 400 {object} util.Message
+// @Router /template-engine/api/v1/_generatebatchasync [POST]
+func (app *Application) generateBatchAsync(w http.ResponseWriter, req *http.Request) {
+	responseURI := req.Header.Get("response_uri")
+	requestBody, ok := readBatchRequest(w, req)
+	if !ok {
+		return
+	}
+
+	asyncJob := job.NewJob(fmt.Sprintf("generate batch: %d items", len(requestBody.Items)))
+	_ = app.jobRepository.AddJob(asyncJob)
+	app.jobRepository.WriteJobResult(w, http.StatusAccepted, asyncJob)
+	go func() {
+		defer app.jobRepository.MakeCallbackToURI(responseURI, asyncJob)
+		result := &BatchResult{Items: make([]*BatchItemResult, 0, len(requestBody.Items))}
+		app.generateBatch(context.Background(), requestBody.Items, func(itemResult *BatchItemResult) {
+			if itemResult.Success {
+				result.Succeeded++
+			} else {
+				result.Failed++
+			}
+			result.Items = append(result.Items, itemResult)
+		})
+		asyncJob.SetResult(&job.Result{Status: http.StatusOK, Data: result})
+	}()
+}
+
+// readBatchRequest reads the batch request and checks that the items have distinct keys.
+func readBatchRequest(w http.ResponseWriter, req *http.Request) (*BatchRequest, bool) {
+	requestBody := &BatchRequest{}
+	if err := util.ReadJSON(req, requestBody); err != nil {
+		log.Error().Err(err).Msg("error in reading the batch request")
+		util.WriteMessage(w, http.StatusBadRequest, fmt.Sprintf("error %v", err))
+		return nil, false
+	}
+	if len(requestBody.Items) == 0 {
+		util.WriteMessage(w, http.StatusBadRequest, "error batch has no items")
+		return nil, false
+	}
+	keys := make(map[string]bool, len(requestBody.Items))
+	for _, item := range requestBody.Items {
+		if item == nil || item.Key == "" {
+			util.WriteMessage(w, http.StatusBadRequest, "error batch item without key")
+			return nil, false
+		}
+		if keys[item.Key] {
+			util.WriteMessage(w, http.StatusBadRequest, fmt.Sprintf("error duplicate batch item key %s", item.Key))
+			return nil, false
+		}
+		keys[item.Key] = true
+	}
+	return requestBody, true
+}
+
+// generateBatch generates the items with at most batchConcurrency items in parallel.
+// The results are passed to done one after another, items which haven't started when the context is done are skipped.
+func (app *Application) generateBatch(ctx context.Context, items []*BatchItem, done func(result *BatchItemResult)) {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	work := make(chan *BatchItem)
+	for i := 0; i < batchConcurrency && i < len(items); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range work {
+				result := app.generateBatchItem(ctx, item)
+				mutex.Lock()
+				done(result)
+				mutex.Unlock()
+			}
+		}()
+	}
+dispatch:
+	for _, item := range items {
+		select {
+		case work <- item:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+}
+
+// generateBatchItem generates the files of a batch item, failures are returned as result with the status
+// and the body a synchronous generation would answer with.
+func (app *Application) generateBatchItem(ctx context.Context, item *BatchItem) *BatchItemResult {
+	result := &BatchItemResult{Key: item.Key}
+	templateFolder, version, err := app.repository.ResolveTemplate(item.TemplateName, item.Version)
+	if err != nil {
+		result.Status, result.Error = errorResponse(err, http.StatusBadRequest)
+		return result
+	}
+	result.Version = version
+	bundle, err := app.repository.GenerateBundle(ctx, templateFolder, item.Variables)
+	if err != nil {
+		result.Status, result.Error = errorResponse(err, http.StatusInternalServerError)
+		return result
+	}
+	for _, file := range bundle.Files {
+		result.Files = append(result.Files, &BatchFile{Name: file.Name, Format: file.Format, Content: string(file.Content)})
+	}
+	result.Success, result.Status = true, http.StatusOK
+	return result
+}
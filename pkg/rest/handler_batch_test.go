@@ -0,0 +1,93 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// flushRecorder records the body written up to each flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed []string
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushed = append(r.flushed, r.Body.String())
+	r.ResponseRecorder.Flush()
+}
+
+func TestApplication_generateBatchSync(t *testing.T) {
+	is := require.New(t)
+	router := newTestRouter(map[string][]byte{
+		"t/config.yaml": []byte("engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n"),
+		"t/main.gotext": []byte("Hi {{.name}}"),
+	})
+	body := `{"items": [{"key": "ok", "template_name": "t", "variables": {"name": "Joe"}}, {"key": "missing", "template_name": "missing"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/template-engine/api/v1/_generatebatch", strings.NewReader(body))
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	router.ServeHTTP(w, req)
+	is.Equal(http.StatusOK, w.Code, w.Body.String())
+	is.Equal(contentTypeNDJSON, w.Header().Get("Content-Type"))
+
+	// Each result is flushed as a line of its own as soon as it is done.
+	is.Len(w.flushed, 2)
+	is.Equal(1, strings.Count(w.flushed[0], "\n"))
+	is.Equal(w.Body.String(), w.flushed[1])
+
+	results := make(map[string]*BatchItemResult)
+	decoder := json.NewDecoder(bytes.NewReader(w.Body.Bytes()))
+	for decoder.More() {
+		result := &BatchItemResult{}
+		is.NoError(decoder.Decode(result))
+		results[result.Key] = result
+	}
+	is.Len(results, 2)
+	is.True(results["ok"].Success)
+	is.Equal(http.StatusOK, results["ok"].Status)
+	is.Len(results["ok"].Files, 1)
+	is.Equal("Hi Joe", results["ok"].Files[0].Content)
+	is.False(results["missing"].Success)
+	is.Equal(http.StatusNotFound, results["missing"].Status)
+}
+
+func TestApplication_generateBatchSync_InvalidRequest(t *testing.T) {
+	router := newTestRouter(map[string][]byte{})
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "no items", body: `{"items": []}`},
+		{name: "item without key", body: `{"items": [{"template_name": "t"}]}`},
+		{name: "duplicate keys", body: `{"items": [{"key": "a", "template_name": "t"}, {"key": "a", "template_name": "t"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/template-engine/api/v1/_generatebatch", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+package rest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/configen"
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// @Summary diff of two generations
+// @Description Generates the file twice and returns the difference between the current and the candidate file.
+// @Description The candidate either uses other variables or another template, e.g. another version of the template.
+// @Description The response contains a unified diff and, for json and json5 outputs, the changed values with their JSON pointers.
+// @Tags template-engine
+// @Accept  json
+// @Produce  json
+// @Param template_name path string true "name of the template"
+// @Param version query string false "semver constraint of the current template version, the version of the body takes precedence"
+// @Param body body DiffRequest true "body"
+// @Success 200 {object} configen.RenderDiff "difference of the generated files"
+// @Failure 400 {object} util.Message
+// @Failure 404 {object} util.Message "template not found"
+// @Failure 422 {object} util.Message "template can't be rendered"
+// @Failure 500 {object} util.Message
+// @Router /template-engine/api/v1/templates/{template_name}/_diff [POST]
+func (app *Application) diffConfiguration(w http.ResponseWriter, req *http.Request) {
+	templateName, ok := util.ValidateAndGetVariableFromPath(w, req, "template_name")
+	if !ok {
+		return
+	}
+	requestBody := &DiffRequest{}
+	if err := util.ReadJSON(req, requestBody); err != nil {
+		log.Error().Err(err).Msg("error in reading the diff request")
+		util.WriteMessage(w, http.StatusBadRequest, fmt.Sprintf("error %v", err))
+		return
+	}
+	constraint := versionConstraint(req, requestBody.Version)
+	currentFolder, _, err := app.repository.ResolveTemplate(templateName, constraint)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	// Without candidate template and version the candidate differs by its variables only.
+	candidateName, candidateConstraint := requestBody.CandidateTemplate, requestBody.CandidateVersion
+	if candidateName == "" {
+		candidateName = templateName
+		if candidateConstraint == "" {
+			candidateConstraint = constraint
+		}
+	}
+	candidateFolder, _, err := app.repository.ResolveTemplate(candidateName, candidateConstraint)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	candidateVariables := requestBody.CandidateVariables
+	if candidateVariables == nil {
+		candidateVariables = requestBody.Variables
+	}
+	result, err := app.repository.DiffFiles(req.Context(),
+		configen.DiffSide{TemplateFolder: currentFolder, Variables: requestBody.Variables},
+		configen.DiffSide{TemplateFolder: candidateFolder, Variables: candidateVariables})
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	util.WriteAsJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"net/http"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/configen"
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+)
+
+// @Summary list all template engines
+// @Description Lists the names of all registered template engines.
+// @Tags template-engine
+// @Produce  json
+// @Success 200 {array} string "list of template engines"
+// @Router /template-engine/api/v1/engines [GET]
+func (app *Application) engines(w http.ResponseWriter, _ *http.Request) {
+	util.WriteAsJSON(w, http.StatusOK, configen.Engines())
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"net/http"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+)
+
+// @Summary features of a template
+// @Description Lists the features of the features.yaml files of a template and whether they are enabled
+// @Description for a software version and platform.
+// @Tags template-engine
+// @Accept  json
+// @Produce  json
+// @Param template_name path string true "name of the template"
+// @Param software_version query string true "software version to check the features against"
+// @Param platform query string false "platform, the default constraints are used if it is not set"
+// @Param version query string false "semver constraint of the template version"
+// @Success 200 {array} configen.FeatureStatus "features sorted by name"
+// @Failure 400 {object} util.Message
+// @Failure 404 {object} util.Message "template not found"
+// @Failure 422 {object} util.Message "invalid features"
+// @Router /template-engine/api/v1/templates/{template_name}/features [GET]
+func (app *Application) features(w http.ResponseWriter, req *http.Request) {
+	templateName, ok := util.ValidateAndGetVariableFromPath(w, req, "template_name")
+	if !ok {
+		return
+	}
+	query := req.URL.Query()
+	softwareVersion := query.Get("software_version")
+	if softwareVersion == "" {
+		util.WriteMessage(w, http.StatusBadRequest, "software_version is not set")
+		return
+	}
+	templateFolder, version, err := app.repository.ResolveTemplate(templateName, query.Get("version"))
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	features, err := app.repository.Features(templateFolder, softwareVersion, query.Get("platform"))
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if version != "" {
+		w.Header().Set(templateVersionHeader, version)
+	}
+	util.WriteAsJSON(w, http.StatusOK, features)
+}
@@ -17,6 +17,8 @@
 package rest
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -27,6 +29,9 @@ import (
 	"github.com/hashicorp/go-retryablehttp"
 )
 
+// templateVersionHeader returns the version of the template used for a synchronous generation
+const templateVersionHeader = "X-Template-Version"
+
 // @Summary generate a configuration file
 // @Description generate a configuration file
 // @Description **Characteristics:**
@@ -36,6 +41,7 @@ import (
 // @Produce  json
 // @Param response_uri header string false "callback response uri"
 // @Param template_name path string true "name of the template"
+// @Param version query string false "semver constraint of the template version, the version of the body takes precedence"
 // @Param body body GenerationRequest true "body"
 // @Header 202 {string} Location "Location to get the job result"
 // @Success 202 "Accepted"
@@ -56,29 +62,51 @@ func (app *Application) generateConfigurationAsync(w http.ResponseWriter, req *h
 		return
 	}
 
+	// The request must not be used by the job, it ends with the response.
+	constraint := versionConstraint(req, requestBody.Version)
 	asyncJob := job.NewJob(fmt.Sprintf("generate configuration: %s", templateName))
 	_ = app.jobRepository.AddJob(asyncJob)
 	app.jobRepository.WriteJobResult(w, http.StatusAccepted, asyncJob)
 	go func() {
 		defer app.jobRepository.MakeCallbackToURI(responseURI, asyncJob)
-		result, _, err := app.repository.GenerateFile(templateName, requestBody.Variables)
+		templateFolder, version, err := app.repository.ResolveTemplate(templateName, constraint)
 		if err != nil {
-			asyncJob.SetResult(job.NewAsyncResultWithMessage(http.StatusBadRequest, fmt.Sprintf("error %v", err)))
+			status, body := errorResponse(err, http.StatusBadRequest)
+			asyncJob.SetResult(&job.Result{Status: status, Data: body})
+			return
+		}
+		bundle, err := app.repository.GenerateBundle(context.Background(), templateFolder, requestBody.Variables)
+		if err != nil {
+			status, body := errorResponse(err, http.StatusInternalServerError)
+			asyncJob.SetResult(&job.Result{Status: status, Data: body})
 			return
 		}
 
-		err = makeCallbackToURI(requestBody.PutBackURL, result)
+		result, contentType := bundle.Files[0].Content, "application/json"
+		if bundle.MultiFile {
+			var archive bytes.Buffer
+			if err := writeBundleTar(&archive, bundle); err != nil {
+				asyncJob.SetResult(job.NewAsyncResultWithMessage(http.StatusInternalServerError, fmt.Sprintf("error %v", err)))
+				return
+			}
+			result, contentType = archive.Bytes(), contentTypeTar
+		}
+		err = makeCallbackToURI(requestBody.PutBackURL, result, contentType)
 		if err != nil {
 			asyncJob.SetResult(job.NewAsyncResultWithMessage(http.StatusBadRequest, fmt.Sprintf("error %v", err)))
 			return
 		}
-		asyncJob.SetResult(job.NewAsyncResult(http.StatusOK))
+		jobResult := job.NewAsyncResult(http.StatusOK)
+		if version != "" {
+			jobResult.Data = &GenerationResult{Version: version}
+		}
+		asyncJob.SetResult(jobResult)
 	}()
 
 }
 
 //makeCallbackToURI Initiate the callback
-func makeCallbackToURI(responseURI string, data []byte) error {
+func makeCallbackToURI(responseURI string, data []byte, contentType string) error {
 	if responseURI != "" {
 		// Create a request
 		req, err := retryablehttp.NewRequest("PUT", responseURI, data)
@@ -87,7 +115,7 @@ func makeCallbackToURI(responseURI string, data []byte) error {
 			return nil
 		}
 
-		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Type", contentType)
 		resp, err := retryablehttp.NewClient().Do(req)
 		if err == nil {
 			defer func() {
@@ -107,13 +135,25 @@ func makeCallbackToURI(responseURI string, data []byte) error {
 // @Description generate a configuration file
 // @Description **Characteristics:**
 // @Description * Operation: **synchronous**
+// @Description Templates with several outputs answer with a bundle of all files,
+// @Description a tar archive by default or a zip archive or multipart/mixed message according to the Accept header.
 // @Tags template-engine
 // @Accept  json
 // @Produce  json
+// @Produce  application/x-tar
+// @Produce  application/zip
+// @Produce  multipart/mixed
 // @Param template_name path string true "name of the template"
+// @Param version query string false "semver constraint of the template version, the version of the body takes precedence"
 // @Param body body GenerationRequest true "body"
-// @Success 200 "config file"
-// @Failure 422 {object} util.Message
+// @Header 200 {string} X-Template-Version "version of the template used for the generation"
+// @Success 200 "config file or bundle of config files"
+// @Failure 400 {object} util.Message
+// @Failure 404 {object} util.Message "template not found"
+// @Failure 422 {object} VariablesErrorMessage "variables don't match the variables schema"
+// @Failure 422 {object} OutputErrorMessage "output doesn't match the output format"
+// @Failure 422 {object} MissingKeyErrorMessage "template accesses a variable which is not set"
+// @Failure 422 {object} RenderErrorMessage "template can't be parsed or executed"
 // @Failure 500 {object} util.Message
 // @Router /template-engine/api/v1/templates/{template_name}/_generatesync [POST]
 func (app *Application) generateConfigurationSync(w http.ResponseWriter, req *http.Request) {
@@ -129,14 +169,35 @@ func (app *Application) generateConfigurationSync(w http.ResponseWriter, req *ht
 		return
 	}
 
-	result, format, err := app.repository.GenerateFile(templateName, requestBody.Variables)
+	templateFolder, version, err := app.repository.ResolveTemplate(templateName, versionConstraint(req, requestBody.Version))
 	if err != nil {
-		util.WriteMessage(w, http.StatusBadRequest, fmt.Sprintf("error %v", err))
+		writeError(w, err, http.StatusBadRequest)
 		return
 	}
-	if format == "json" || format == "json5" {
+	bundle, err := app.repository.GenerateBundle(req.Context(), templateFolder, requestBody.Variables)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if version != "" {
+		w.Header().Set(templateVersionHeader, version)
+	}
+	if bundle.MultiFile {
+		writeBundle(w, req, bundle)
+		return
+	}
+	file := bundle.Files[0]
+	if file.Format == "json" || file.Format == "json5" {
 		w.Header().Set("Content-Type", "application/json")
 	}
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(result)
+	_, _ = w.Write(file.Content)
+}
+
+// versionConstraint returns the version constraint of the request body or, if not set, of the version query parameter.
+func versionConstraint(req *http.Request, bodyVersion string) string {
+	if bodyVersion != "" {
+		return bodyVersion
+	}
+	return req.URL.Query().Get("version")
 }
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// @Summary lint a template
+// @Description Checks the config and the files of a template without generating a file.
+// @Description Reports undefined template calls, unused and duplicate defines, unknown functions, patterns matching no file
+// @Description and unknown attributes of the config.yaml. With variables all outputs are rendered as dry run.
+// @Description The body is optional.
+// @Tags template-engine
+// @Accept  json
+// @Produce  json
+// @Param template_name path string true "name of the template"
+// @Param version query string false "semver constraint of the template version, the version of the body takes precedence"
+// @Param body body LintRequest false "body"
+// @Success 200 {object} configen.LintResult "issues of the template"
+// @Failure 400 {object} util.Message
+// @Failure 404 {object} util.Message "template not found"
+// @Router /template-engine/api/v1/templates/{template_name}/_lint [POST]
+func (app *Application) lintTemplate(w http.ResponseWriter, req *http.Request) {
+	templateName, ok := util.ValidateAndGetVariableFromPath(w, req, "template_name")
+	if !ok {
+		return
+	}
+	requestBody := &LintRequest{}
+	if err := util.ReadJSON(req, requestBody); err != nil && err != io.EOF {
+		log.Error().Err(err).Msg("error in reading the lint request")
+		util.WriteMessage(w, http.StatusBadRequest, fmt.Sprintf("error %v", err))
+		return
+	}
+	templateFolder, version, err := app.repository.ResolveTemplate(templateName, versionConstraint(req, requestBody.Version))
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	result, err := app.repository.LintTemplate(req.Context(), templateFolder, requestBody.Variables)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if version != "" {
+		w.Header().Set(templateVersionHeader, version)
+	}
+	util.WriteAsJSON(w, http.StatusOK, result)
+}
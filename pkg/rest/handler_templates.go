@@ -0,0 +1,235 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/configen"
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// @Summary list all templates
+// @Description Lists all templates of the template folder.
+// @Description A folder is treated as template if it contains a config.yaml.
+// @Tags template-engine
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} configen.TemplateInfo "list of templates"
+// @Failure 500 {object} util.Message
+// @Router /template-engine/api/v1/templates [GET]
+func (app *Application) templates(w http.ResponseWriter, _ *http.Request) {
+	templates, err := app.repository.Templates()
+	if err != nil {
+		log.Error().Err(err).Msg("error in getting templates")
+		util.WriteMessage(w, http.StatusInternalServerError, "error in getting templates")
+		return
+	}
+	util.WriteAsJSON(w, http.StatusOK, templates)
+}
+
+// @Summary get a template
+// @Description Returns the config and the resolved main and include files of a template.
+// @Description Versioned templates are returned in the highest version matching the version constraint.
+// @Tags template-engine
+// @Accept  json
+// @Produce  json
+// @Param template_name path string true "name of the template"
+// @Param version query string false "semver constraint of the template version"
+// @Success 200 {object} configen.TemplateInfo "template"
+// @Failure 400 {object} util.Message "invalid version constraint"
+// @Failure 404 {object} util.Message "template not found"
+// @Failure 500 {object} util.Message
+// @Router /template-engine/api/v1/templates/{template_name} [GET]
+func (app *Application) template(w http.ResponseWriter, req *http.Request) {
+	templateName, ok := util.ValidateAndGetVariableFromPath(w, req, "template_name")
+	if !ok {
+		return
+	}
+	template, err := app.repository.Template(templateName, req.URL.Query().Get("version"))
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	util.WriteAsJSON(w, http.StatusOK, template)
+}
+
+// maxTemplateUploadSize limits the size of an uploaded template
+const maxTemplateUploadSize = 32 << 20
+
+// @Summary create or replace a template
+// @Description Uploads the files of a template either as tar.gz archive or as multipart form.
+// @Description For multipart forms the form name of each part is used as path inside the template folder,
+// @Description if it is not set the file name is used.
+// @Description The template is validated before it is activated.
+// @Description With a version the files are stored as that version of the template.
+// @Tags template-engine
+// @Accept  application/gzip
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param template_name path string true "name of the template"
+// @Param version query string false "semver version of the template"
+// @Success 200 {object} configen.TemplateInfo "template replaced"
+// @Success 201 {object} configen.TemplateInfo "template created"
+// @Failure 400 {object} util.Message
+// @Failure 405 {object} util.Message "template store is read only"
+// @Failure 409 {object} util.Message "template is inside another template or contains other templates"
+// @Failure 413 {object} util.Message "template exceeds the upload limit"
+// @Failure 415 {object} util.Message
+// @Failure 422 {object} util.Message
+// @Failure 500 {object} util.Message
+// @Router /template-engine/api/v1/templates/{template_name} [PUT]
+func (app *Application) putTemplate(w http.ResponseWriter, req *http.Request) {
+	templateName, ok := templateFolderFromRequest(w, req)
+	if !ok {
+		return
+	}
+	body := &uploadBody{ReadCloser: http.MaxBytesReader(w, req.Body, maxTemplateUploadSize)}
+	req.Body = body
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	var files map[string][]byte
+	var err error
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		files, err = readMultipartFiles(req)
+	case mediaType == "application/gzip", mediaType == "application/x-gzip",
+		mediaType == "application/x-tar+gzip", mediaType == "application/octet-stream":
+		files, err = configen.ReadTarGz(req.Body)
+	default:
+		util.WriteMessage(w, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type %q", mediaType))
+		return
+	}
+	if body.tooLarge() {
+		util.WriteMessage(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("template exceeds the upload limit of %d bytes", maxTemplateUploadSize))
+		return
+	}
+	if err != nil {
+		util.WriteMessage(w, http.StatusBadRequest, fmt.Sprintf("error %v", err))
+		return
+	}
+	created, err := app.repository.PutTemplate(templateName, files)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	template, err := app.repository.Template(templateName, "")
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if created {
+		util.WriteAsJSON(w, http.StatusCreated, template)
+		return
+	}
+	util.WriteAsJSON(w, http.StatusOK, template)
+}
+
+// uploadBody records the read error of the request body, the readers of the upload wrap it into their own errors.
+type uploadBody struct {
+	io.ReadCloser
+	err error
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// tooLarge reports whether the body exceeded the limit of the http.MaxBytesReader.
+func (b *uploadBody) tooLarge() bool {
+	return b.err != nil && b.err.Error() == "http: request body too large"
+}
+
+func readMultipartFiles(req *http.Request) (map[string][]byte, error) {
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := part.FormName()
+		if name == "" {
+			name = part.FileName()
+		}
+		content, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+	return files, nil
+}
+
+// @Summary delete a template
+// @Description Deletes a template from the template folder.
+// @Description With a version only that version of the template is deleted.
+// @Tags template-engine
+// @Produce  json
+// @Param template_name path string true "name of the template"
+// @Param version query string false "semver version of the template"
+// @Success 204 "template deleted"
+// @Failure 404 {object} util.Message "template not found"
+// @Failure 405 {object} util.Message "template store is read only"
+// @Failure 409 {object} util.Message "template contains other templates"
+// @Failure 500 {object} util.Message
+// @Router /template-engine/api/v1/templates/{template_name} [DELETE]
+func (app *Application) deleteTemplate(w http.ResponseWriter, req *http.Request) {
+	templateName, ok := templateFolderFromRequest(w, req)
+	if !ok {
+		return
+	}
+	err := app.repository.DeleteTemplate(templateName)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// templateFolderFromRequest returns the template folder addressed by the template name and the optional version query parameter.
+func templateFolderFromRequest(w http.ResponseWriter, req *http.Request) (string, bool) {
+	templateName, ok := util.ValidateAndGetVariableFromPath(w, req, "template_name")
+	if !ok {
+		return "", false
+	}
+	version := req.URL.Query().Get("version")
+	if version == "" {
+		return templateName, true
+	}
+	templateFolder, err := configen.TemplateVersionFolder(templateName, version)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return "", false
+	}
+	return templateFolder, true
+}
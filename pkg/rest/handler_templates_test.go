@@ -0,0 +1,75 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/configen"
+)
+
+// newTestRouter routes the requests to an application with the templates of the memory store.
+func newTestRouter(files map[string][]byte) http.Handler {
+	router := mux.NewRouter()
+	NewApplication(configen.NewStoreRepository(configen.NewMemoryStore(files)), nil).Routes(router)
+	return router
+}
+
+func TestApplication_putTemplate(t *testing.T) {
+	router := newTestRouter(map[string][]byte{})
+	part := "--boundary\r\nContent-Disposition: form-data; name=\"main.gotext\"\r\n\r\n"
+	tests := []struct {
+		name       string
+		body       io.Reader
+		wantStatus int
+	}{
+		{
+			name: "template",
+			body: strings.NewReader("--boundary\r\nContent-Disposition: form-data; name=\"config.yaml\"\r\n\r\n" +
+				"engine: golang\nmain_template: main.gotext\nmain_pattern: \"*.gotext\"\n\r\n" +
+				part + "Hi\r\n--boundary--\r\n"),
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "invalid multipart form",
+			body:       strings.NewReader("--other\r\n"),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too large",
+			body:       io.MultiReader(strings.NewReader(part), bytes.NewReader(make([]byte, maxTemplateUploadSize+1))),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/template-engine/api/v1/templates/t", tt.body)
+			req.Header.Set("Content-Type", "multipart/form-data; boundary=boundary")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2022 RtBrick Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.  You may obtain a copy
+ * of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package rest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/leitstand/leitstand-template-engine/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// @Summary effective variables of a generation
+// @Description Returns the request variables merged over the default variables of the template.
+// @Description These are the variables a generation with the same request would use.
+// @Tags template-engine
+// @Accept  json
+// @Produce  json
+// @Param template_name path string true "name of the template"
+// @Param version query string false "semver constraint of the template version, the version of the body takes precedence"
+// @Param body body GenerationRequest true "body"
+// @Success 200 {object} map[string]interface{} "effective variables"
+// @Failure 400 {object} util.Message
+// @Failure 404 {object} util.Message "template not found"
+// @Router /template-engine/api/v1/templates/{template_name}/_effectivevariables [POST]
+func (app *Application) effectiveVariables(w http.ResponseWriter, req *http.Request) {
+	templateName, ok := util.ValidateAndGetVariableFromPath(w, req, "template_name")
+	if !ok {
+		return
+	}
+	requestBody := &GenerationRequest{}
+	err := util.ReadJSON(req, requestBody)
+	if err != nil {
+		log.Error().Err(err).Msg("error in reading the generation request")
+		util.WriteMessage(w, http.StatusBadRequest, fmt.Sprintf("error %v", err))
+		return
+	}
+	templateFolder, version, err := app.repository.ResolveTemplate(templateName, versionConstraint(req, requestBody.Version))
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	variables, err := app.repository.EffectiveVariables(templateFolder, requestBody.Variables)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	if version != "" {
+		w.Header().Set(templateVersionHeader, version)
+	}
+	util.WriteAsJSON(w, http.StatusOK, variables)
+}
+
+// @Summary variables of a template
+// @Description Returns the variables read by the templates of a template, discovered from the parsed templates,
+// @Description together with a skeleton variable set to start a variables file.
+// @Tags template-engine
+// @Accept  json
+// @Produce  json
+// @Param template_name path string true "name of the template"
+// @Param version query string false "semver constraint of the template version"
+// @Success 200 {object} configen.TemplateVariables "variables of the template"
+// @Failure 400 {object} util.Message
+// @Failure 404 {object} util.Message "template not found"
+// @Failure 501 {object} util.Message "template engine doesn't support the discovery"
+// @Router /template-engine/api/v1/templates/{template_name}/variables [GET]
+func (app *Application) templateVariables(w http.ResponseWriter, req *http.Request) {
+	templateName, ok := util.ValidateAndGetVariableFromPath(w, req, "template_name")
+	if !ok {
+		return
+	}
+	templateFolder, version, err := app.repository.ResolveTemplate(templateName, req.URL.Query().Get("version"))
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	variables, err := app.repository.TemplateVariables(templateFolder)
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if version != "" {
+		w.Header().Set(templateVersionHeader, version)
+	}
+	util.WriteAsJSON(w, http.StatusOK, variables)
+}
@@ -21,4 +21,89 @@ type GenerationRequest struct {
 	PutBackURL string `json:"put_back_url"`
 	//Variables for the generation
 	Variables map[string]interface{} `json:"variables"`
+	//Version is a semver constraint selecting the template version, the highest version is used if not set
+	Version string `json:"version,omitempty"`
+}
+
+// LintRequest to lint a template, with variables the template is rendered as dry run
+type LintRequest struct {
+	//Variables for the dry run, the template is only checked if not set
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	//Version is a semver constraint selecting the template version, the highest version is used if not set
+	Version string `json:"version,omitempty"`
+}
+
+// DiffRequest to compare the files generated for two variable sets or two templates
+type DiffRequest struct {
+	//Variables for the generation of the current file
+	Variables map[string]interface{} `json:"variables"`
+	//Version is a semver constraint selecting the current template version, the highest version is used if not set
+	Version string `json:"version,omitempty"`
+	//CandidateVariables for the generation of the candidate file, the variables are used if not set
+	CandidateVariables map[string]interface{} `json:"candidate_variables,omitempty"`
+	//CandidateTemplate is the name of the candidate template, the template of the path is used if not set
+	CandidateTemplate string `json:"candidate_template,omitempty"`
+	//CandidateVersion is a semver constraint selecting the candidate template version,
+	//without candidate template the version of the current file is used if not set
+	CandidateVersion string `json:"candidate_version,omitempty"`
+}
+
+// BatchRequest to generate the configs of many devices in one request
+type BatchRequest struct {
+	//Items to generate, the keys of the items have to be distinct
+	Items []*BatchItem `json:"items"`
+}
+
+// BatchItem is a single generation of a batch
+type BatchItem struct {
+	//Key identifies the item in the results, e.g. the name of the device
+	Key string `json:"key"`
+	//TemplateName is the name of the template
+	TemplateName string `json:"template_name"`
+	//Variables for the generation
+	Variables map[string]interface{} `json:"variables"`
+	//Version is a semver constraint selecting the template version, the highest version is used if not set
+	Version string `json:"version,omitempty"`
+}
+
+// BatchItemResult is the result of a batch item
+type BatchItemResult struct {
+	//Key of the item
+	Key string `json:"key"`
+	//Success reports whether the files of the item were generated
+	Success bool `json:"success"`
+	//Status is the http status a synchronous generation of the item would answer with
+	Status int `json:"status"`
+	//Version of the template used for the generation, only set for versioned templates
+	Version string `json:"version,omitempty"`
+	//Files are the generated files, one for each output of the template
+	Files []*BatchFile `json:"files,omitempty"`
+	//Error is the body a synchronous generation of the item would answer with
+	Error interface{} `json:"error,omitempty"`
+}
+
+// BatchFile is a generated file of a batch item
+type BatchFile struct {
+	//Name of the output, not set for templates without declared outputs
+	Name string `json:"name,omitempty"`
+	//Format of the output (e.g. json)
+	Format string `json:"format,omitempty"`
+	//Content is the generated content
+	Content string `json:"content"`
+}
+
+// BatchResult is the job result of an asynchronous batch generation
+type BatchResult struct {
+	//Succeeded is the number of generated items
+	Succeeded int `json:"succeeded"`
+	//Failed is the number of failed items
+	Failed int `json:"failed"`
+	//Items are the results of the items in the order the items were done
+	Items []*BatchItemResult `json:"items"`
+}
+
+// GenerationResult is the job result of a successful asynchronous generation of a versioned template
+type GenerationResult struct {
+	//Version of the template used for the generation
+	Version string `json:"version"`
 }
@@ -24,6 +24,19 @@ import (
 
 // Routes returns all routes for this application
 func (app *Application) Routes(router *mux.Router) {
+	router.Path("/template-engine/api/v1/engines").Methods(http.MethodGet).HandlerFunc(app.engines)
+	router.Path("/template-engine/api/v1/cache").Methods(http.MethodGet).HandlerFunc(app.cacheStats)
+	router.Path("/template-engine/api/v1/_generatebatch").Methods(http.MethodPost).HandlerFunc(app.generateBatchSync)
+	router.Path("/template-engine/api/v1/_generatebatchasync").Methods(http.MethodPost).HandlerFunc(app.generateBatchAsync)
+	router.Path("/template-engine/api/v1/templates").Methods(http.MethodGet).HandlerFunc(app.templates)
+	router.Path("/template-engine/api/v1/templates/{template_name}").Methods(http.MethodGet).HandlerFunc(app.template)
+	router.Path("/template-engine/api/v1/templates/{template_name}").Methods(http.MethodPut).HandlerFunc(app.putTemplate)
+	router.Path("/template-engine/api/v1/templates/{template_name}").Methods(http.MethodDelete).HandlerFunc(app.deleteTemplate)
 	router.Path("/template-engine/api/v1/templates/{template_name}/_generatesync").Methods(http.MethodPost).HandlerFunc(app.generateConfigurationSync)
 	router.Path("/template-engine/api/v1/templates/{template_name}/_generate").Methods(http.MethodPost).HandlerFunc(app.generateConfigurationAsync)
+	router.Path("/template-engine/api/v1/templates/{template_name}/_diff").Methods(http.MethodPost).HandlerFunc(app.diffConfiguration)
+	router.Path("/template-engine/api/v1/templates/{template_name}/_effectivevariables").Methods(http.MethodPost).HandlerFunc(app.effectiveVariables)
+	router.Path("/template-engine/api/v1/templates/{template_name}/variables").Methods(http.MethodGet).HandlerFunc(app.templateVariables)
+	router.Path("/template-engine/api/v1/templates/{template_name}/_lint").Methods(http.MethodPost).HandlerFunc(app.lintTemplate)
+	router.Path("/template-engine/api/v1/templates/{template_name}/features").Methods(http.MethodGet).HandlerFunc(app.features)
 }